@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"store_backend/environment"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const issuer = "store_backend"
+
+var ErrInvalidToken = errors.New("invalid access token")
+
+// TokenIssuer signs and verifies JWT access tokens. Tokens are signed with the
+// current key; tokens signed with any of the previous keys are still accepted
+// so keys can be rotated without logging everyone out.
+type TokenIssuer struct {
+	keyID     string
+	keys      map[string][]byte
+	accessTTL time.Duration
+}
+
+func NewTokenIssuer(env environment.Environment) *TokenIssuer {
+	t := &TokenIssuer{
+		keyID:     keyID(env.JWTSigningKey),
+		keys:      map[string][]byte{},
+		accessTTL: env.AccessTokenTTL,
+	}
+
+	t.keys[t.keyID] = env.JWTSigningKey
+	for _, key := range env.JWTPreviousKeys {
+		t.keys[keyID(key)] = key
+	}
+
+	return t
+}
+
+// IssueAccessToken returns a signed access token for the user and its expiry.
+func (t *TokenIssuer) IssueAccessToken(userID uint) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(t.accessTTL)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	token.Header["kid"] = t.keyID
+
+	signed, err := token.SignedString(t.keys[t.keyID])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken verifies the token and returns the ID of its user.
+func (t *TokenIssuer) ParseAccessToken(token string) (uint, error) {
+	claims := jwt.RegisteredClaims{}
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad subject %q", ErrInvalidToken, claims.Subject)
+	}
+
+	return uint(userID), nil
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"store_backend/models"
+	"store_backend/problem"
+	"store_backend/repositories"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	userContextKey = "user"
+	roleContextKey = "role"
+)
+
+// Authenticate identifies the caller from the bearer token of the request, if
+// there is one. Configured API tokens only carry a role; any other token must
+// be a JWT access token, whose user is stored in the context. Requests without
+// a token pass through anonymously; use RequireUser on routes that need a
+// signed in user and Require for permission checks.
+func Authenticate(tokens *TokenIssuer, apiTokens map[string]string, users *repositories.UserRepository) echo.MiddlewareFunc {
+	apiRoles := map[string]models.Role{}
+	for token, role := range apiTokens {
+		if !models.Role(role).Valid() {
+			panic(fmt.Errorf("invalid role %q for API token", role))
+		}
+		apiRoles[HashToken(token)] = models.Role(role)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := BearerToken(c)
+			if !ok {
+				return next(c)
+			}
+
+			if role, ok := apiRoles[HashToken(token)]; ok {
+				c.Set(roleContextKey, role)
+				return next(c)
+			}
+
+			userID, err := tokens.ParseAccessToken(token)
+			if err != nil {
+				return unauthorized(c)
+			}
+
+			user, err := users.GetByID(userID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return unauthorized(c)
+				}
+				return problem.Internal(err, "Failed to authenticate request")
+			}
+
+			c.Set(userContextKey, user)
+			return next(c)
+		}
+	}
+}
+
+// RequireUser rejects requests that Authenticate did not resolve to a user.
+func RequireUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if CurrentUser(c) == nil {
+			return unauthorized(c)
+		}
+		return next(c)
+	}
+}
+
+// CurrentUser returns the signed in user, or nil for anonymous requests.
+func CurrentUser(c echo.Context) *models.User {
+	user, _ := c.Get(userContextKey).(*models.User)
+	return user
+}
+
+// CurrentUserID returns the ID of the signed in user, or nil for anonymous
+// requests.
+func CurrentUserID(c echo.Context) *uint {
+	if user := CurrentUser(c); user != nil {
+		return &user.ID
+	}
+	return nil
+}
+
+// BearerToken returns the token from the request's Authorization header.
+func BearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func unauthorized(c echo.Context) error {
+	return problem.New(http.StatusUnauthorized, "Missing or invalid credentials")
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"net/http"
+	"store_backend/models"
+	"store_backend/problem"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Permission string
+
+const (
+	PermCatalogRead   Permission = "catalog:read"
+	PermCatalogWrite  Permission = "catalog:write"
+	PermInventory     Permission = "inventory:manage"
+	PermCartUse       Permission = "cart:use"
+	PermOrdersRead    Permission = "orders:read"
+	PermUsersManage   Permission = "users:manage"
+	PermAPIKeysManage Permission = "api_keys:manage"
+	PermPromotions    Permission = "promotions:manage"
+	PermTaxes         Permission = "taxes:manage"
+	PermCurrencies    Permission = "currencies:manage"
+	PermShipping      Permission = "shipping:manage"
+	PermPayments      Permission = "payments:manage"
+)
+
+// Permissions lists every permission, in the order they are documented.
+var Permissions = []Permission{
+	PermCatalogRead, PermCatalogWrite, PermInventory,
+	PermCartUse, PermOrdersRead, PermUsersManage, PermAPIKeysManage,
+	PermPromotions, PermTaxes, PermCurrencies, PermShipping, PermPayments,
+}
+
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleAdmin: Permissions,
+	models.RoleCatalogManager: {
+		PermCatalogRead, PermCatalogWrite, PermInventory,
+	},
+	models.RoleCustomer: {
+		PermCatalogRead, PermCartUse, PermOrdersRead,
+	},
+	models.RoleGuest: {
+		PermCatalogRead,
+		PermCartUse,
+	},
+}
+
+func HasPermission(role models.Role, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if string(p) == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Holds reports whether the caller of the request has the permission.
+// Requests made with an API key are checked against the key's scopes, all
+// others against the caller's role.
+func Holds(c echo.Context, permission Permission) bool {
+	if key := CurrentAPIKey(c); key != nil {
+		return key.HasScope(string(permission))
+	}
+	return HasPermission(CurrentRole(c), permission)
+}
+
+// Require rejects requests whose caller lacks the permission, see Holds. It
+// has to run after Authenticate.
+func Require(permission Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if Holds(c, permission) {
+				return next(c)
+			}
+
+			if CurrentAPIKey(c) != nil {
+				return problem.Newf(http.StatusForbidden, "API key is missing scope %s", permission).
+					With("permission", permission)
+			}
+
+			role := CurrentRole(c)
+
+			if role == models.RoleGuest {
+				return unauthorized(c)
+			}
+
+			return problem.Newf(http.StatusForbidden, "Role %s is missing permission %s", role, permission).
+				With("role", role).
+				With("permission", permission)
+		}
+	}
+}
+
+// CurrentRole returns the role the request acts with: the signed in user's
+// role, the role of a configured API token, or RoleGuest.
+func CurrentRole(c echo.Context) models.Role {
+	if user := CurrentUser(c); user != nil {
+		return user.Role
+	}
+	if role, ok := c.Get(roleContextKey).(models.Role); ok {
+		return role
+	}
+	return models.RoleGuest
+}
@@ -15,21 +15,83 @@ func Initialize(env environment.Environment) *gorm.DB {
 		slogGorm.WithHandler(env.Logger.Handler()),
 	)
 
-	db, err := gorm.Open(sqlite.Open(env.DSN), &gorm.Config{Logger: gormLogger})
+	db, err := gorm.Open(sqlite.Open(env.DSN), &gorm.Config{Logger: gormLogger, TranslateError: true})
 	if err != nil {
 		panic(err)
 	}
 
+	backfillAvailable := db.Migrator().HasTable(&models.Product{}) &&
+		!db.Migrator().HasColumn(&models.Product{}, "AvailableQuantity")
+	backfillCurrencies := db.Migrator().HasTable(&models.Order{}) &&
+		!db.Migrator().HasColumn(&models.Order{}, "Currency")
+	backfillSubtotals := db.Migrator().HasTable(&models.Order{}) &&
+		!db.Migrator().HasColumn(&models.Order{}, "Subtotal")
+
 	err = db.AutoMigrate(
+		&models.User{},
+		&models.Session{},
+		&models.Address{},
+		&models.APIKey{},
+		&models.TaxClass{},
+		&models.TaxRate{},
 		&models.Product{},
+		&models.ProductPrice{},
+		&models.ProductTranslation{},
+		&models.ExchangeRate{},
+		&models.StockMovement{},
 		&models.Category{},
+		&models.CategoryTranslation{},
 		&models.Cart{},
+		&models.CartItem{},
+		&models.CartStatusChange{},
+		&models.ShippingZone{},
+		&models.ShippingMethod{},
+		&models.Coupon{},
+		&models.Promotion{},
+		&models.Order{},
+		&models.OrderLine{},
+		&models.OrderCoupon{},
+		&models.OrderPromotion{},
+		&models.OrderTax{},
+		&models.Payment{},
 	)
 
 	if err != nil {
 		panic(err)
 	}
 
+	if err := migrateCartProducts(db); err != nil {
+		panic(err)
+	}
+
+	if err := abandonTokenlessGuestCarts(db, env); err != nil {
+		panic(err)
+	}
+
+	if backfillAvailable {
+		if err := backfillAvailableQuantity(db); err != nil {
+			panic(err)
+		}
+	}
+
+	if backfillSubtotals {
+		if err := backfillOrderSubtotals(db); err != nil {
+			panic(err)
+		}
+	}
+
+	if backfillCurrencies {
+		if err := backfillOrderCurrencies(db, env.BaseCurrency); err != nil {
+			panic(err)
+		}
+	}
+
+	if env.ExchangeRatesFile != "" {
+		if err := loadExchangeRates(db, env.ExchangeRatesFile, env.BaseCurrency); err != nil {
+			panic(err)
+		}
+	}
+
 	if env.ENV == environment.Development && os.Getenv("SEED") == "true" {
 		if err := Seed(db); err != nil {
 			panic(err)
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"store_backend/pricing"
+	"store_backend/repositories"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// exchangeRatesFile is the format of EXCHANGE_RATES_FILE, for example
+// {"base": "PLN", "rates": {"EUR": 0.2315, "USD": 0.2512}}.
+type exchangeRatesFile struct {
+	Base  string                     `json:"base"`
+	Rates map[string]decimal.Decimal `json:"rates"`
+}
+
+// loadExchangeRates stores the rates from the file, replacing the rates of the
+// currencies it lists. The file has to be for the configured base currency.
+func loadExchangeRates(db *gorm.DB, path string, base string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file exchangeRatesFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if !strings.EqualFold(file.Base, base) {
+		return fmt.Errorf("exchange rates in %s are for %s, the base currency is %s", path, file.Base, base)
+	}
+
+	rates := make(map[string]decimal.Decimal, len(file.Rates))
+	for code, rate := range file.Rates {
+		rates[strings.ToUpper(code)] = rate
+	}
+
+	if err := pricing.ValidateExchangeRates(base, rates); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	_, err = repositories.NewExchangeRateRepository(db).SetAll(rates)
+	return err
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"store_backend/environment"
+	"store_backend/models"
+	"store_backend/repositories"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrateCartProducts moves rows from the legacy cart_products many2many table
+// into cart_items and drops the old table. It is a no-op once the table is gone.
+func migrateCartProducts(db *gorm.DB) error {
+	if !db.Migrator().HasTable("cart_products") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			CartID    uint
+			ProductID uint
+			Quantity  int
+			Price     decimal.Decimal
+		}
+
+		err := tx.Table("cart_products").
+			Select("cart_products.cart_id, cart_products.product_id, COUNT(*) AS quantity, products.price").
+			Joins("JOIN products ON products.id = cart_products.product_id").
+			Group("cart_products.cart_id, cart_products.product_id, products.price").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		items := make([]models.CartItem, len(rows))
+		for i, row := range rows {
+			items[i] = models.CartItem{
+				CartID:    row.CartID,
+				ProductID: row.ProductID,
+				Quantity:  row.Quantity,
+				UnitPrice: row.Price,
+			}
+		}
+
+		if len(items) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropTable("cart_products")
+	})
+}
+
+// abandonTokenlessGuestCarts abandons the guest carts created before guest
+// carts had tokens, see CartRepository.AbandonTokenlessGuestCarts.
+func abandonTokenlessGuestCarts(db *gorm.DB, env environment.Environment) error {
+	abandoned, err := repositories.NewCartRepository(db, env.ReservationTTL).AbandonTokenlessGuestCarts()
+	if abandoned > 0 {
+		env.Logger.Info("abandoned guest carts without a token", "carts", abandoned)
+	}
+	return err
+}
+
+// backfillAvailableQuantity makes all existing stock available. It runs once,
+// right after the available_quantity column has been added.
+func backfillAvailableQuantity(db *gorm.DB) error {
+	return db.Unscoped().Model(&models.Product{}).
+		Where("1 = 1").
+		Update("available_quantity", gorm.Expr("stock_quantity")).Error
+}
+
+// backfillOrderSubtotals sets the subtotal of orders placed before coupons
+// existed, which had no discount. It runs once, right after the subtotal column
+// has been added.
+func backfillOrderSubtotals(db *gorm.DB) error {
+	return db.Unscoped().Model(&models.Order{}).
+		Where("1 = 1").
+		Updates(map[string]interface{}{"subtotal": gorm.Expr("total"), "discount": 0}).Error
+}
+
+// backfillOrderCurrencies marks orders placed before prices had a currency as
+// placed in the base currency. It runs once, right after the currency column
+// has been added.
+func backfillOrderCurrencies(db *gorm.DB, base string) error {
+	return db.Unscoped().Model(&models.Order{}).
+		Where("1 = 1").
+		Updates(map[string]interface{}{"currency": base, "exchange_rate": 1}).Error
+}
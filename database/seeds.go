@@ -2,20 +2,51 @@ package database
 
 import (
 	"math/rand"
+	"store_backend/auth"
 	"store_backend/models"
+	"store_backend/repositories"
 
 	"github.com/go-faker/faker/v4"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
-func createCategories(db *gorm.DB) ([]models.Category, error) {
+func createTaxes(db *gorm.DB) ([]models.TaxClass, error) {
+	classes := []models.TaxClass{
+		{Name: "standard"},
+		{Name: "reduced"},
+		{Name: "zero"},
+	}
+
+	if err := db.Create(&classes).Error; err != nil {
+		return nil, err
+	}
+
+	standard, reduced, zero := classes[0].ID, classes[1].ID, classes[2].ID
+	rates := []models.TaxRate{
+		{TaxClassID: standard, Country: "PL", Name: "VAT 23%", Rate: decimal.NewFromInt(23)},
+		{TaxClassID: reduced, Country: "PL", Name: "VAT 5%", Rate: decimal.NewFromInt(5)},
+		{TaxClassID: zero, Country: "PL", Name: "VAT 0%", Rate: decimal.Zero},
+		{TaxClassID: standard, Country: "DE", Name: "MwSt 19%", Rate: decimal.NewFromInt(19)},
+		{TaxClassID: reduced, Country: "DE", Name: "MwSt 7%", Rate: decimal.NewFromInt(7)},
+		{TaxClassID: zero, Country: "DE", Name: "MwSt 0%", Rate: decimal.Zero},
+	}
+
+	if err := db.Create(&rates).Error; err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+func createCategories(db *gorm.DB, taxClasses []models.TaxClass) ([]models.Category, error) {
+	standard, reduced := &taxClasses[0].ID, &taxClasses[1].ID
+
 	categories := []models.Category{
-		{Name: "Electronics"},
-		{Name: "Clothing"},
-		{Name: "Books"},
-		{Name: "Home & Kitchen"},
-		{Name: "Sports & Outdoors"},
+		{Name: "Electronics", TaxClassID: standard, Translations: polish("Elektronika")},
+		{Name: "Clothing", TaxClassID: standard, Translations: polish("Odzież")},
+		{Name: "Books", TaxClassID: reduced, Translations: polish("Książki")},
+		{Name: "Home & Kitchen", TaxClassID: standard, Translations: polish("Dom i kuchnia")},
+		{Name: "Sports & Outdoors", TaxClassID: standard, Translations: polish("Sport i turystyka")},
 	}
 
 	for i := range categories {
@@ -26,16 +57,27 @@ func createCategories(db *gorm.DB) ([]models.Category, error) {
 	return categories, nil
 }
 
+func polish(name string) []models.CategoryTranslation {
+	return []models.CategoryTranslation{{Locale: "pl", Name: name}}
+}
+
 func createProducts(db *gorm.DB, categories []models.Category) ([]models.Product, error) {
 	products := make([]models.Product, 0)
 	for _, category := range categories {
 		numProducts := rand.Intn(6) + 5 // 5-10 products per category
 		for i := 0; i < numProducts; i++ {
 			price := decimal.NewFromFloat(float64(rand.Intn(99400)+599) / 100)
+			stock := rand.Intn(50)
 			product := models.Product{
-				Name:       faker.Word() + " " + faker.Word(),
-				Price:      price,
-				CategoryID: &category.ID,
+				Name:              faker.Word() + " " + faker.Word(),
+				Price:             price,
+				StockQuantity:     stock,
+				AvailableQuantity: stock,
+				CategoryID:        &category.ID,
+				Weight:            decimal.New(int64(rand.Intn(4950)+50), -3), // 0.05-5 kg
+				Length:            decimal.NewFromInt(int64(rand.Intn(80) + 5)),
+				Width:             decimal.NewFromInt(int64(rand.Intn(50) + 5)),
+				Height:            decimal.NewFromInt(int64(rand.Intn(30) + 1)),
 			}
 			if err := db.Create(&product).Error; err != nil {
 				return nil, err
@@ -46,37 +88,187 @@ func createProducts(db *gorm.DB, categories []models.Category) ([]models.Product
 	return products, nil
 }
 
-func createCarts(db *gorm.DB, products []models.Product, numCarts int) error {
+func createUsers(db *gorm.DB) ([]models.User, error) {
+	hash, err := auth.HashPassword("password123")
+	if err != nil {
+		return nil, err
+	}
+
+	users := []models.User{
+		{Email: "demo@example.com", Name: "Demo User", Role: models.RoleCustomer, PasswordHash: hash},
+		{Email: "admin@example.com", Name: "Admin", Role: models.RoleAdmin, PasswordHash: hash},
+	}
+
+	for i := range users {
+		if err := db.Create(&users[i]).Error; err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func createCarts(db *gorm.DB, users []models.User, products []models.Product, numCarts int) error {
 	for i := 0; i < numCarts; i++ {
 		cart := models.Cart{}
+
+		// Every other cart belongs to a user, the rest are guest carts
+		if i%2 == 0 {
+			cart.UserID = &users[0].ID
+		}
+
 		if err := db.Create(&cart).Error; err != nil {
 			return err
 		}
 
-		// Add random products to cart (1-5 products)
+		// Add random products to cart (1-5 products, 1-3 units each)
 		numProductsInCart := rand.Intn(5) + 1
-		cartProducts := make([]models.Product, 0, numProductsInCart)
+		cartItems := make([]models.CartItem, 0, numProductsInCart)
 
-		for j := 0; j < numProductsInCart; j++ {
-			randomProduct := products[rand.Intn(len(products))]
-			cartProducts = append(cartProducts, randomProduct)
+		for _, j := range rand.Perm(len(products))[:numProductsInCart] {
+			randomProduct := products[j]
+			cartItems = append(cartItems, models.CartItem{
+				CartID:    cart.ID,
+				ProductID: randomProduct.ID,
+				Quantity:  rand.Intn(3) + 1,
+				UnitPrice: randomProduct.Price,
+			})
 		}
 
-		if err := db.Model(&cart).Association("Products").Append(cartProducts); err != nil {
+		if err := db.Create(&cartItems).Error; err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func createCoupons(db *gorm.DB, categories []models.Category) error {
+	usageLimit := 100
+
+	coupons := []models.Coupon{
+		{Code: "WELCOME10", Type: models.CouponPercentage, Value: decimal.NewFromInt(10)},
+		{Code: "MINUS50", Type: models.CouponFixedAmount, Value: decimal.NewFromInt(50), MinCartValue: decimal.NewFromInt(300)},
+		{Code: "FREESHIP", Type: models.CouponFreeShipping, UsageLimit: &usageLimit},
+		{Code: "BOOKS20", Type: models.CouponPercentage, Value: decimal.NewFromInt(20), CategoryIDs: []uint{categories[2].ID}},
+	}
+
+	return db.Create(&coupons).Error
+}
+
+func createPromotions(db *gorm.DB, categories []models.Category) error {
+	promotions := []models.Promotion{
+		{
+			Name:         "Buy 3 Books, get the cheapest free",
+			Type:         models.PromotionBuyXGetY,
+			CategoryID:   &categories[2].ID,
+			BuyQuantity:  3,
+			FreeQuantity: 1,
+			Active:       true,
+		},
+		{
+			Name:       "10% off Electronics over 500",
+			Type:       models.PromotionTieredPercentage,
+			CategoryID: &categories[0].ID,
+			Tiers:      []models.PromotionTier{{Threshold: decimal.NewFromInt(500), Percentage: decimal.NewFromInt(10)}},
+			Active:     true,
+		},
+	}
+
+	return db.Create(&promotions).Error
+}
+
+func createAddresses(db *gorm.DB, users []models.User) error {
+	address := models.Address{
+		UserID: users[0].ID,
+		PostalAddress: models.PostalAddress{
+			Name:     users[0].Name,
+			Line1:    "ul. Marszałkowska 1",
+			City:     "Warszawa",
+			Postcode: "00-950",
+			Country:  "PL",
+		},
+		DefaultShipping: true,
+		DefaultBilling:  true,
+	}
+	return db.Create(&address).Error
+}
+
+func createShipping(db *gorm.DB) error {
+	zones := []models.ShippingZone{
+		{Name: "Poland", Countries: []string{"PL"}},
+		{Name: "Warsaw", Countries: []string{"PL"}, Postcodes: []string{"00", "01", "02", "03", "04"}},
+		{Name: "Europe", Countries: []string{"DE", "CZ", "SK", "LT", "FR"}},
+	}
+	if err := db.Create(&zones).Error; err != nil {
+		return err
+	}
+
+	poland, warsaw, europe := zones[0].ID, zones[1].ID, zones[2].ID
+	methods := []models.ShippingMethod{
+		{ZoneID: poland, Name: "Parcel locker", Type: models.ShippingFlat, Price: decimal.RequireFromString("12.99"),
+			MaxWeight: decimal.NewFromInt(25), MaxLength: decimal.NewFromInt(64), Active: true},
+		{ZoneID: poland, Name: "Courier", Type: models.ShippingWeightBased, Active: true, Tiers: []models.ShippingTier{
+			{Threshold: decimal.Zero, Price: decimal.RequireFromString("16.99")},
+			{Threshold: decimal.NewFromInt(10), Price: decimal.RequireFromString("24.99")},
+			{Threshold: decimal.NewFromInt(30), Price: decimal.RequireFromString("49.99")},
+		}},
+		{ZoneID: warsaw, Name: "Same day courier", Type: models.ShippingPriceTiered, Active: true, Tiers: []models.ShippingTier{
+			{Threshold: decimal.Zero, Price: decimal.RequireFromString("29.99")},
+			{Threshold: decimal.NewFromInt(500), Price: decimal.Zero},
+		}},
+		{ZoneID: warsaw, Name: "Parcel locker", Type: models.ShippingFlat, Price: decimal.RequireFromString("9.99"),
+			MaxWeight: decimal.NewFromInt(25), MaxLength: decimal.NewFromInt(64), Active: true},
+		{ZoneID: europe, Name: "International courier", Type: models.ShippingWeightBased, Active: true, Tiers: []models.ShippingTier{
+			{Threshold: decimal.Zero, Price: decimal.RequireFromString("59.99")},
+			{Threshold: decimal.NewFromInt(10), Price: decimal.RequireFromString("99.99")},
+		}},
+	}
+	return db.Create(&methods).Error
+}
+
+func createExchangeRates(db *gorm.DB) error {
+	_, err := repositories.NewExchangeRateRepository(db).SetAll(map[string]decimal.Decimal{
+		"EUR": decimal.RequireFromString("0.2325"),
+		"USD": decimal.RequireFromString("0.2500"),
+	})
+	return err
+}
+
 func Seed(db *gorm.DB) error {
-	db.Exec("DELETE FROM cart_products")
+	db.Exec("DELETE FROM payments")
+	db.Exec("DELETE FROM order_taxes")
+	db.Exec("DELETE FROM order_promotions")
+	db.Exec("DELETE FROM order_coupons")
+	db.Exec("DELETE FROM order_lines")
+	db.Exec("DELETE FROM orders")
+	db.Exec("DELETE FROM cart_coupons")
+	db.Exec("DELETE FROM coupons")
+	db.Exec("DELETE FROM promotions")
+	db.Exec("DELETE FROM cart_items")
+	db.Exec("DELETE FROM cart_status_changes")
+	db.Exec("DELETE FROM stock_movements")
+	db.Exec("DELETE FROM product_prices")
+	db.Exec("DELETE FROM product_translations")
 	db.Exec("DELETE FROM products")
+	db.Exec("DELETE FROM category_translations")
 	db.Exec("DELETE FROM categories")
+	db.Exec("DELETE FROM tax_rates")
+	db.Exec("DELETE FROM tax_classes")
+	db.Exec("DELETE FROM exchange_rates")
+	db.Exec("DELETE FROM shipping_methods")
+	db.Exec("DELETE FROM shipping_zones")
 	db.Exec("DELETE FROM carts")
+	db.Exec("DELETE FROM sessions")
+	db.Exec("DELETE FROM addresses")
+	db.Exec("DELETE FROM users")
+
+	// Create tax classes and rates
+	taxClasses, err := createTaxes(db)
+	if err != nil {
+		return err
+	}
 
 	// Create categories
-	categories, err := createCategories(db)
+	categories, err := createCategories(db, taxClasses)
 	if err != nil {
 		return err
 	}
@@ -87,8 +279,39 @@ func Seed(db *gorm.DB) error {
 		return err
 	}
 
+	// Create users
+	users, err := createUsers(db)
+	if err != nil {
+		return err
+	}
+
+	// Create addresses
+	if err := createAddresses(db, users); err != nil {
+		return err
+	}
+
 	// Create carts
-	if err := createCarts(db, products, 5); err != nil {
+	if err := createCarts(db, users, products, 5); err != nil {
+		return err
+	}
+
+	// Create coupons
+	if err := createCoupons(db, categories); err != nil {
+		return err
+	}
+
+	// Create promotions
+	if err := createPromotions(db, categories); err != nil {
+		return err
+	}
+
+	// Create shipping zones and methods
+	if err := createShipping(db); err != nil {
+		return err
+	}
+
+	// Create exchange rates
+	if err := createExchangeRates(db); err != nil {
 		return err
 	}
 
@@ -5,6 +5,8 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -23,6 +25,52 @@ type Environment struct {
 	ENV          RuntimeEnvironment
 	FRONTEND_URL string
 	Logger       *slog.Logger
+
+	// JWTSigningKey signs new access tokens. Tokens signed with one of the
+	// JWTPreviousKeys are still accepted, which allows rotating the key.
+	JWTSigningKey   []byte
+	JWTPreviousKeys [][]byte
+	// APITokens maps static bearer tokens to the role they act with.
+	APITokens map[string]string
+	// AccessTokenTTL is how long a JWT access token is valid.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long a login stays valid without being refreshed.
+	RefreshTokenTTL time.Duration
+
+	// ReservationTTL is how long products added to a cart stay reserved.
+	ReservationTTL time.Duration
+	// ReservationSweepInterval is how often expired reservations are released.
+	ReservationSweepInterval time.Duration
+	// UsageLogInterval is how often the requests to each API version are
+	// logged, zero or less turns the log off.
+	UsageLogInterval time.Duration
+	// CartMergePolicy is how a guest cart is merged into the user's cart on
+	// login: merge, replace or keep_newest.
+	CartMergePolicy string
+
+	// PricesIncludeTax tells whether product prices are gross or net.
+	PricesIncludeTax bool
+	// TaxRounding is where tax is rounded: per line or per total.
+	TaxRounding string
+	// DefaultCountry is used for tax when a cart has no delivery country.
+	DefaultCountry string
+
+	// BaseCurrency is the currency product prices are stored in.
+	BaseCurrency string
+	// ExchangeRatesFile is a JSON file with exchange rates loaded at start.
+	ExchangeRatesFile string
+
+	// DefaultLocale is the locale of requests that accept none of the
+	// supported locales, and of product and category names.
+	DefaultLocale string
+
+	// PaymentProvider is the payment service provider checkout charges
+	// through. Only "fake", which simulates one, is built in.
+	PaymentProvider string
+	// PaymentWebhookSecret verifies the signatures of provider callbacks.
+	PaymentWebhookSecret []byte
+	// PaymentAutoCapture captures payments as soon as they are authorized.
+	PaymentAutoCapture bool
 }
 
 func Initialize() Environment {
@@ -41,6 +89,30 @@ func Initialize() Environment {
 		// FRONTEND_URL: "http://192.168.117.3:3000",
 		FRONTEND_URL: os.Getenv("FRONTEND_URL"),
 		Logger:       initializeLogger(env),
+
+		JWTSigningKey:   []byte(getRequiredEnv("JWT_SIGNING_KEY")),
+		JWTPreviousKeys: getListEnv("JWT_PREVIOUS_SIGNING_KEYS"),
+		APITokens:       getMapEnv("API_TOKENS"),
+		AccessTokenTTL:  getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		ReservationTTL:           getPositiveDurationEnv("RESERVATION_TTL", 15*time.Minute),
+		ReservationSweepInterval: getPositiveDurationEnv("RESERVATION_SWEEP_INTERVAL", time.Minute),
+		UsageLogInterval:         getDurationEnv("USAGE_LOG_INTERVAL", time.Hour),
+		CartMergePolicy:          getEnv("CART_MERGE_POLICY", "merge"),
+
+		PricesIncludeTax: getBoolEnv("PRICES_INCLUDE_TAX", false),
+		TaxRounding:      getEnv("TAX_ROUNDING", "line"),
+		DefaultCountry:   strings.ToUpper(getEnv("DEFAULT_COUNTRY", "PL")),
+
+		BaseCurrency:      strings.ToUpper(getEnv("BASE_CURRENCY", "PLN")),
+		ExchangeRatesFile: os.Getenv("EXCHANGE_RATES_FILE"),
+
+		DefaultLocale: strings.ToLower(getEnv("DEFAULT_LOCALE", "en")),
+
+		PaymentProvider:      getEnv("PAYMENT_PROVIDER", "fake"),
+		PaymentWebhookSecret: []byte(os.Getenv("PAYMENT_WEBHOOK_SECRET")),
+		PaymentAutoCapture:   getBoolEnv("PAYMENT_AUTO_CAPTURE", true),
 	}
 }
 
@@ -54,6 +126,84 @@ func getRequiredEnv(s string) string {
 	return x
 }
 
+func getEnv(s string, fallback string) string {
+	if x := os.Getenv(s); x != "" {
+		return x
+	}
+
+	return fallback
+}
+
+func getDurationEnv(s string, fallback time.Duration) time.Duration {
+	x := os.Getenv(s)
+
+	if x == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(x)
+	if err != nil {
+		panic(fmt.Errorf("invalid duration in env variable %s: %w", s, err))
+	}
+
+	return d
+}
+
+// getPositiveDurationEnv is getDurationEnv for durations that cannot be zero
+// or negative, such as the reservation lifetime and sweep interval.
+func getPositiveDurationEnv(s string, fallback time.Duration) time.Duration {
+	d := getDurationEnv(s, fallback)
+
+	if d <= 0 {
+		panic(fmt.Errorf("env variable %s must be a positive duration, got %s", s, d))
+	}
+
+	return d
+}
+
+func getBoolEnv(s string, fallback bool) bool {
+	x := os.Getenv(s)
+
+	if x == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(x)
+	if err != nil {
+		panic(fmt.Errorf("invalid boolean in env variable %s: %w", s, err))
+	}
+
+	return b
+}
+
+// getListEnv splits a comma separated env variable, skipping empty entries.
+func getListEnv(s string) [][]byte {
+	var list [][]byte
+
+	for _, x := range strings.Split(os.Getenv(s), ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			list = append(list, []byte(x))
+		}
+	}
+
+	return list
+}
+
+// getMapEnv parses a comma separated list of key=value pairs.
+func getMapEnv(s string) map[string]string {
+	m := map[string]string{}
+
+	for _, pair := range getListEnv(s) {
+		key, value, ok := strings.Cut(string(pair), "=")
+		if !ok {
+			panic(fmt.Errorf("invalid entry in env variable %s: expected key=value", s))
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return m
+}
+
 func parseRuntimeEnvironment(s string) RuntimeEnvironment {
 	switch s {
 	case "production":
@@ -14,38 +14,73 @@ function announce_and_execute
     eval $argv
 end
 
+announce 'http POST :1323/v1/auth/login (admin)'
+set adminToken (http POST :1323/v1/auth/login email=admin@example.com password=password123 | jq -r .accessToken)
+set adminAuth "-A bearer -a $adminToken"
+
 set commands \
-    'http GET :1323/products' \
-    'http GET :1323/products/12' \
-    'http PUT :1323/products/12 name="Hair dryer" price:=39.99 categoryId:=2' \
-    'http GET :1323/products/12' \
-    'http DELETE :1323/products/12' \
-    'http GET :1323/products/12' \
+    'http GET :1323/v1/products' \
+    'http GET :1323/v1/products page==2 pageSize==5 sort==-price minPrice==100' \
+    'http GET :1323/v1/products/12' \
+    "http $adminAuth PUT :1323/v1/products/12/translations/pl name='Suszarka do włosów'" \
+    'http GET :1323/v1/products/12 Accept-Language:pl' \
+    'http GET :1323/v1/categories Accept-Language:pl' \
+    'http GET :1323/v1/products/999 Accept-Language:pl' \
+    "http $adminAuth PUT :1323/v1/products/12 name='Hair dryer' price:=39.99 categoryId:=2" \
+    'http GET :1323/v1/products/12' \
+    "http $adminAuth DELETE :1323/v1/products/12" \
+    'http GET :1323/v1/products/12' \
 
 for command in $commands
     announce_and_execute $command
 end
 
-announce 'http POST :1323/carts'
-set response (eval 'http POST :1323/carts')
+announce 'http POST :1323/v1/carts (guest)'
+set guestCart (http POST :1323/v1/carts)
+set guestCartID (echo $guestCart | jq .id)
+set guestCartToken (echo $guestCart | jq -r .token)
+announce_and_execute "http POST :1323/v1/carts/$guestCartID/products/18 X-Cart-Token:$guestCartToken quantity:=2"
+
+announce 'http POST :1323/v1/auth/login (merging the guest cart)'
+set login (http POST :1323/v1/auth/login email=demo@example.com password=password123 guestCartId:=$guestCartID guestCartToken=$guestCartToken)
+echo $login | jq '{cartId}'
+set token (echo $login | jq -r .accessToken)
+set auth "-A bearer -a $token"
+
+announce 'http POST :1323/v1/carts'
+set response (eval "http $auth POST :1323/v1/carts")
 echo $response
 
-set cartID (echo $response | jq .ID)
+set cartID (echo $response | jq .id)
 
 announce "Using cart ID: $cartID"
 
 set commands \
-    "http GET :1323/carts/$cartID/products" \
-    "http POST :1323/carts/$cartID/products/18" \
-    "http POST :1323/carts/$cartID/products/21" \
-    "http POST :1323/carts/$cartID/products/23" \
-    "http GET :1323/carts/$cartID/products" \
-    "http DELETE :1323/carts/$cartID/products/18" \
-    "http GET :1323/carts/$cartID/products" \
-    "http DELETE :1323/carts/$cartID/products" \
-    "http GET :1323/carts/$cartID/products" \
-    "http DELETE :1323/carts/$cartID" \
-    "http GET :1323/carts/$cartID" \
+    "http $auth GET :1323/v1/addresses" \
+    "http $auth POST :1323/v1/addresses name='Demo User' line1='Unter den Linden 1' city=Berlin postcode=10117 country=DE" \
+    "http $auth GET :1323/v1/carts/$cartID/products" \
+    "http $auth POST :1323/v1/carts/$cartID/products/18" \
+    "http $auth POST :1323/v1/carts/$cartID/products/21" \
+    "http $auth POST :1323/v1/carts/$cartID/products/23" \
+    "http $auth POST :1323/v1/carts/$cartID/products/23 quantity:=2" \
+    "http $auth PUT :1323/v1/carts/$cartID/products/21 quantity:=5" \
+    "http $auth POST :1323/v1/carts/$cartID/coupons code=WELCOME10" \
+    "http $auth DELETE :1323/v1/carts/$cartID/coupons/WELCOME10" \
+    "http $auth PUT :1323/v1/carts/$cartID/destination country=PL postcode=00-950" \
+    "http $auth GET :1323/v1/carts/$cartID/shipping-options" \
+    "http $auth PUT :1323/v1/carts/$cartID/destination country=DE" \
+    "http $auth GET :1323/v1/carts/$cartID/shipping-options" \
+    "http $auth GET :1323/v1/carts/$cartID/products currency==EUR" \
+    "http $auth GET :1323/v1/carts/$cartID/payments" \
+    "http $adminAuth GET :1323/v1/admin/payments" \
+    "http $auth GET :1323/v1/carts/$cartID/products" \
+    "http $auth DELETE :1323/v1/carts/$cartID/products/18" \
+    "http $auth DELETE :1323/v1/carts/$cartID/products/21 all==true" \
+    "http $auth GET :1323/v1/carts/$cartID/products" \
+    "http $auth DELETE :1323/v1/carts/$cartID/products" \
+    "http $auth GET :1323/v1/carts/$cartID/products" \
+    "http $auth DELETE :1323/v1/carts/$cartID" \
+    "http $auth GET :1323/v1/carts/$cartID" \
 
 for command in $commands
     announce_and_execute $command
@@ -5,12 +5,15 @@ go 1.24.2
 require (
 	github.com/go-faker/faker/v4 v4.6.0
 	github.com/go-playground/validator/v10 v10.26.0
+	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/joho/godotenv v1.5.1
 	github.com/labstack/echo/v4 v4.13.3
 	github.com/orandin/slog-gorm v1.4.0
 	github.com/samber/slog-echo v1.16.1
 	github.com/shopspring/decimal v1.4.0
 	gitlab.com/greyxor/slogor v1.6.1
+	golang.org/x/crypto v0.33.0
+	golang.org/x/text v0.24.0
 	gorm.io/driver/sqlite v1.5.7
 	gorm.io/gorm v1.25.12
 )
@@ -31,9 +34,7 @@ require (
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	go.opentelemetry.io/otel v1.29.0 // indirect
 	go.opentelemetry.io/otel/trace v1.29.0 // indirect
-	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
-	golang.org/x/text v0.24.0 // indirect
 	golang.org/x/time v0.8.0 // indirect
 )
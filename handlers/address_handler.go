@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"store_backend/auth"
+	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/problem"
+	"store_backend/repositories"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AddressHandler struct {
+	repos        repositories.Repositories
+	authenticate echo.MiddlewareFunc
+}
+
+func (h *AddressHandler) RegisterRoutes(g *echo.Group) error {
+	addresses := g.Group("/addresses", h.authenticate, auth.Require(auth.PermCartUse), auth.RequireUser)
+
+	addresses.GET("", h.GetAddresses)
+	addresses.GET("/:id", h.GetAddress)
+	addresses.POST("", h.CreateAddress)
+	addresses.PUT("/:id", h.UpdateAddress)
+	addresses.DELETE("/:id", h.DeleteAddress)
+
+	return nil
+}
+
+// Operations documents the routes registered by RegisterRoutes.
+func (h *AddressHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/addresses", Response: []models.Address{}},
+		{Method: http.MethodGet, Path: "/addresses/:id", Request: GetAddressRequest{}, Response: models.Address{}},
+		{Method: http.MethodPost, Path: "/addresses", Request: CreateAddressRequest{}, Response: models.Address{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/addresses/:id", Request: UpdateAddressRequest{}, Response: models.Address{}},
+		{Method: http.MethodDelete, Path: "/addresses/:id", Request: DeleteAddressRequest{}},
+	}
+}
+
+func (h *AddressHandler) GetAddresses(c echo.Context) error {
+	addresses, err := h.repos.Addresses.GetAllByUser(auth.CurrentUser(c).ID)
+	if err != nil {
+		return problem.Internal(err, "Failed to get addresses")
+	}
+
+	return c.JSON(http.StatusOK, addresses)
+}
+
+type GetAddressRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AddressHandler) GetAddress(c echo.Context) error {
+	data := GetAddressRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	address, err := findOwnAddress(c, h.repos.Addresses, data.ID)
+	if err != nil {
+		return h.handleAddressError(c, err, "Failed to get address")
+	}
+
+	return c.JSON(http.StatusOK, address)
+}
+
+// AddressRequest is a postal address. The postcode and region are checked
+// against the format of the country.
+type AddressRequest struct {
+	Name     string `json:"name" validate:"required,max=200"`
+	Company  string `json:"company" validate:"max=200"`
+	Line1    string `json:"line1" validate:"required,max=200"`
+	Line2    string `json:"line2" validate:"max=200"`
+	City     string `json:"city" validate:"required,max=100"`
+	Region   string `json:"region" validate:"max=100"`
+	Postcode string `json:"postcode" validate:"max=20"`
+	Country  string `json:"country" validate:"required,len=2,alpha"`
+	Phone    string `json:"phone" validate:"omitempty,max=30,e164"`
+}
+
+// postcodeFormats are the postcode formats of the countries the store ships
+// to most. Countries without an entry accept any postcode.
+var postcodeFormats = map[string]*regexp.Regexp{
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"CZ": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SK": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"LT": regexp.MustCompile(`^(LT-)?\d{5}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+}
+
+// regionRequired lists the countries whose addresses need a state or
+// province.
+var regionRequired = map[string]bool{"US": true, "CA": true, "AU": true}
+
+// postalAddress checks the address against the format of its country and
+// returns it normalized.
+func (r AddressRequest) postalAddress() (models.PostalAddress, error) {
+	address := models.PostalAddress{
+		Name:     strings.TrimSpace(r.Name),
+		Company:  strings.TrimSpace(r.Company),
+		Line1:    strings.TrimSpace(r.Line1),
+		Line2:    strings.TrimSpace(r.Line2),
+		City:     strings.TrimSpace(r.City),
+		Region:   strings.TrimSpace(r.Region),
+		Postcode: strings.ToUpper(strings.TrimSpace(r.Postcode)),
+		Country:  strings.ToUpper(r.Country),
+		Phone:    r.Phone,
+	}
+
+	if format, ok := postcodeFormats[address.Country]; ok && !format.MatchString(address.Postcode) {
+		return address, problem.Newf(http.StatusBadRequest, "Invalid postcode for %s", address.Country)
+	}
+
+	if regionRequired[address.Country] && address.Region == "" {
+		return address, problem.Newf(http.StatusBadRequest, "Region is required for %s", address.Country)
+	}
+
+	return address, nil
+}
+
+type CreateAddressRequest struct {
+	AddressRequest
+	DefaultShipping bool `json:"defaultShipping"`
+	DefaultBilling  bool `json:"defaultBilling"`
+}
+
+// CreateAddress adds an address to the caller's address book. Their first
+// address becomes the default for both shipping and billing.
+func (h *AddressHandler) CreateAddress(c echo.Context) error {
+	data := CreateAddressRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	postal, err := data.postalAddress()
+	if err != nil {
+		return err
+	}
+
+	address, err := h.repos.Addresses.Create(&models.Address{
+		UserID:          auth.CurrentUser(c).ID,
+		PostalAddress:   postal,
+		DefaultShipping: data.DefaultShipping,
+		DefaultBilling:  data.DefaultBilling,
+	})
+	if err != nil {
+		return h.handleAddressError(c, err, "Failed to create address")
+	}
+
+	return c.JSON(http.StatusCreated, address)
+}
+
+type UpdateAddressRequest struct {
+	ID uint `param:"id" validate:"required"`
+	CreateAddressRequest
+}
+
+// UpdateAddress replaces the address. Orders already placed keep the address
+// they were shipped to.
+func (h *AddressHandler) UpdateAddress(c echo.Context) error {
+	data := UpdateAddressRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	postal, err := data.postalAddress()
+	if err != nil {
+		return err
+	}
+
+	address, err := findOwnAddress(c, h.repos.Addresses, data.ID)
+	if err != nil {
+		return h.handleAddressError(c, err, "Failed to update address")
+	}
+
+	address.PostalAddress = postal
+	address.DefaultShipping = data.DefaultShipping
+	address.DefaultBilling = data.DefaultBilling
+
+	address, err = h.repos.Addresses.Update(address)
+	if err != nil {
+		return h.handleAddressError(c, err, "Failed to update address")
+	}
+
+	return c.JSON(http.StatusOK, address)
+}
+
+type DeleteAddressRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AddressHandler) DeleteAddress(c echo.Context) error {
+	data := DeleteAddressRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := findOwnAddress(c, h.repos.Addresses, data.ID); err != nil {
+		return h.handleAddressError(c, err, "Failed to delete address")
+	}
+
+	if err := h.repos.Addresses.Delete(data.ID); err != nil {
+		return h.handleAddressError(c, err, "Failed to delete address")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AddressHandler) handleAddressError(c echo.Context, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, AddressNotFound)
+	}
+	return problem.Internal(err, message)
+}
+
+// findOwnAddress loads an address of the signed in user, reporting addresses
+// of other users as not found.
+func findOwnAddress(c echo.Context, addresses *repositories.AddressRepository, id uint) (*models.Address, error) {
+	address, err := addresses.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ownedByCaller(c, &address.UserID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return address, nil
+}
+
+const (
+	AddressNotFound = "Address not found"
+)
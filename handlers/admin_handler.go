@@ -0,0 +1,1168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"store_backend/auth"
+	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/pricing"
+	"store_backend/problem"
+	"store_backend/repositories"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	repos        repositories.Repositories
+	authenticate echo.MiddlewareFunc
+	baseCurrency string
+}
+
+func (h *AdminHandler) RegisterRoutes(g *echo.Group) error {
+	admin := g.Group("/admin", h.authenticate)
+
+	users := admin.Group("/users", auth.Require(auth.PermUsersManage))
+	users.GET("", h.GetUsers)
+	users.PUT("/:id/role", h.SetUserRole)
+
+	apiKeys := admin.Group("/api-keys", auth.Require(auth.PermAPIKeysManage))
+	apiKeys.GET("", h.GetAPIKeys)
+	apiKeys.POST("", h.CreateAPIKey)
+	apiKeys.PUT("/:id/scopes", h.SetAPIKeyScopes)
+	apiKeys.DELETE("/:id", h.RevokeAPIKey)
+
+	coupons := admin.Group("/coupons", auth.Require(auth.PermPromotions))
+	coupons.GET("", h.GetCoupons)
+	coupons.POST("", h.CreateCoupon)
+	coupons.PUT("/:id", h.UpdateCoupon)
+	coupons.DELETE("/:id", h.DeleteCoupon)
+
+	promotions := admin.Group("/promotions", auth.Require(auth.PermPromotions))
+	promotions.GET("", h.GetPromotions)
+	promotions.POST("", h.CreatePromotion)
+	promotions.PUT("/:id", h.UpdatePromotion)
+	promotions.DELETE("/:id", h.DeletePromotion)
+
+	taxClasses := admin.Group("/tax-classes", auth.Require(auth.PermTaxes))
+	taxClasses.GET("", h.GetTaxClasses)
+	taxClasses.POST("", h.CreateTaxClass)
+	taxClasses.PUT("/:id", h.UpdateTaxClass)
+	taxClasses.DELETE("/:id", h.DeleteTaxClass)
+
+	taxRates := admin.Group("/tax-rates", auth.Require(auth.PermTaxes))
+	taxRates.GET("", h.GetTaxRates)
+	taxRates.POST("", h.CreateTaxRate)
+	taxRates.PUT("/:id", h.UpdateTaxRate)
+	taxRates.DELETE("/:id", h.DeleteTaxRate)
+
+	shippingZones := admin.Group("/shipping-zones", auth.Require(auth.PermShipping))
+	shippingZones.GET("", h.GetShippingZones)
+	shippingZones.POST("", h.CreateShippingZone)
+	shippingZones.PUT("/:id", h.UpdateShippingZone)
+	shippingZones.DELETE("/:id", h.DeleteShippingZone)
+
+	shippingMethods := admin.Group("/shipping-methods", auth.Require(auth.PermShipping))
+	shippingMethods.POST("", h.CreateShippingMethod)
+	shippingMethods.PUT("/:id", h.UpdateShippingMethod)
+	shippingMethods.DELETE("/:id", h.DeleteShippingMethod)
+
+	exchangeRates := admin.Group("/exchange-rates", auth.Require(auth.PermCurrencies))
+	exchangeRates.GET("", h.GetExchangeRates)
+	exchangeRates.PUT("", h.SetExchangeRates)
+	exchangeRates.DELETE("/:currency", h.DeleteExchangeRate)
+
+	payments := admin.Group("/payments", auth.Require(auth.PermPayments))
+	payments.GET("", h.GetPayments)
+	payments.POST("/:id/capture", h.CapturePayment)
+	payments.POST("/:id/refund", h.RefundPayment)
+	payments.POST("/:id/void", h.VoidPayment)
+
+	return nil
+}
+
+// Operations documents the routes registered by RegisterRoutes.
+func (h *AdminHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/admin/users", Response: []models.User{}},
+		{Method: http.MethodPut, Path: "/admin/users/:id/role", Request: SetUserRoleRequest{}, Response: models.User{}},
+
+		{Method: http.MethodGet, Path: "/admin/api-keys", Response: []models.APIKey{}},
+		{Method: http.MethodPost, Path: "/admin/api-keys", Request: CreateAPIKeyRequest{}, Response: CreateAPIKeyResponse{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/api-keys/:id/scopes", Request: SetAPIKeyScopesRequest{}, Response: models.APIKey{}},
+		{Method: http.MethodDelete, Path: "/admin/api-keys/:id", Request: RevokeAPIKeyRequest{}, Response: models.APIKey{}},
+
+		{Method: http.MethodGet, Path: "/admin/coupons", Response: []models.Coupon{}},
+		{Method: http.MethodPost, Path: "/admin/coupons", Request: CouponRequest{}, Response: models.Coupon{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/coupons/:id", Request: UpdateCouponRequest{}, Response: models.Coupon{}},
+		{Method: http.MethodDelete, Path: "/admin/coupons/:id", Request: DeleteCouponRequest{}},
+
+		{Method: http.MethodGet, Path: "/admin/promotions", Response: []models.Promotion{}},
+		{Method: http.MethodPost, Path: "/admin/promotions", Request: PromotionRequest{}, Response: models.Promotion{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/promotions/:id", Request: UpdatePromotionRequest{}, Response: models.Promotion{}},
+		{Method: http.MethodDelete, Path: "/admin/promotions/:id", Request: DeletePromotionRequest{}},
+
+		{Method: http.MethodGet, Path: "/admin/tax-classes", Response: []models.TaxClass{}},
+		{Method: http.MethodPost, Path: "/admin/tax-classes", Request: CreateTaxClassRequest{}, Response: models.TaxClass{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/tax-classes/:id", Request: UpdateTaxClassRequest{}, Response: models.TaxClass{}},
+		{Method: http.MethodDelete, Path: "/admin/tax-classes/:id", Request: DeleteTaxClassRequest{}},
+
+		{Method: http.MethodGet, Path: "/admin/tax-rates", Response: []models.TaxRate{}},
+		{Method: http.MethodPost, Path: "/admin/tax-rates", Request: TaxRateRequest{}, Response: models.TaxRate{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/tax-rates/:id", Request: UpdateTaxRateRequest{}, Response: models.TaxRate{}},
+		{Method: http.MethodDelete, Path: "/admin/tax-rates/:id", Request: DeleteTaxRateRequest{}},
+
+		{Method: http.MethodGet, Path: "/admin/shipping-zones", Response: []models.ShippingZone{}},
+		{Method: http.MethodPost, Path: "/admin/shipping-zones", Request: ShippingZoneRequest{}, Response: models.ShippingZone{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/shipping-zones/:id", Request: UpdateShippingZoneRequest{}, Response: models.ShippingZone{}},
+		{Method: http.MethodDelete, Path: "/admin/shipping-zones/:id", Request: DeleteShippingZoneRequest{}},
+
+		{Method: http.MethodPost, Path: "/admin/shipping-methods", Request: ShippingMethodRequest{}, Response: models.ShippingMethod{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/admin/shipping-methods/:id", Request: UpdateShippingMethodRequest{}, Response: models.ShippingMethod{}},
+		{Method: http.MethodDelete, Path: "/admin/shipping-methods/:id", Request: DeleteShippingMethodRequest{}},
+
+		{Method: http.MethodGet, Path: "/admin/exchange-rates", Response: ExchangeRatesResponse{}},
+		{Method: http.MethodPut, Path: "/admin/exchange-rates", Request: SetExchangeRatesRequest{}, Response: ExchangeRatesResponse{}},
+		{Method: http.MethodDelete, Path: "/admin/exchange-rates/:currency", Request: DeleteExchangeRateRequest{}},
+
+		{Method: http.MethodGet, Path: "/admin/payments", Response: []models.Payment{}},
+		{Method: http.MethodPost, Path: "/admin/payments/:id/capture", Request: PaymentRequest{}, Response: models.Payment{}},
+		{Method: http.MethodPost, Path: "/admin/payments/:id/refund", Request: RefundPaymentRequest{}, Response: models.Payment{}},
+		{Method: http.MethodPost, Path: "/admin/payments/:id/void", Request: PaymentRequest{}, Response: models.Payment{}},
+	}
+}
+
+func (h *AdminHandler) GetUsers(c echo.Context) error {
+	users, err := h.repos.Users.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get users")
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+type SetUserRoleRequest struct {
+	ID   uint        `param:"id" validate:"required"`
+	Role models.Role `json:"role" validate:"required,oneof=admin catalog_manager customer"`
+}
+
+func (h *AdminHandler) SetUserRole(c echo.Context) error {
+	data := SetUserRoleRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	// An admin demoting themselves could leave nobody able to manage roles.
+	if caller := auth.CurrentUser(c); caller != nil && caller.ID == data.ID && data.Role != models.RoleAdmin {
+		return problem.New(http.StatusConflict, "Admins cannot change their own role")
+	}
+
+	user, err := h.repos.Users.UpdateRole(data.ID, data.Role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, "User not found")
+		}
+		return problem.Internal(err, "Failed to update user role")
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) GetAPIKeys(c echo.Context) error {
+	keys, err := h.repos.APIKeys.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get API keys")
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,permission"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKeyResponse is the only response that contains the key itself.
+type CreateAPIKeyResponse struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+func (h *AdminHandler) CreateAPIKey(c echo.Context) error {
+	data := CreateAPIKeyRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if data.ExpiresAt != nil && data.ExpiresAt.Before(time.Now()) {
+		return problem.New(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	if err := checkGrantable(c, data.Scopes); err != nil {
+		return err
+	}
+
+	raw, hash, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return problem.Internal(err, FailedCreateAPIKey)
+	}
+
+	key, err := h.repos.APIKeys.Create(&models.APIKey{
+		Name:        data.Name,
+		Prefix:      prefix,
+		KeyHash:     hash,
+		Scopes:      data.Scopes,
+		CreatedByID: auth.CurrentUserID(c),
+		ExpiresAt:   data.ExpiresAt,
+	})
+	if err != nil {
+		return problem.Internal(err, FailedCreateAPIKey)
+	}
+
+	return c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: key, Key: raw})
+}
+
+type SetAPIKeyScopesRequest struct {
+	ID     uint     `param:"id" validate:"required"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,permission"`
+}
+
+func (h *AdminHandler) SetAPIKeyScopes(c echo.Context) error {
+	data := SetAPIKeyScopesRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := checkGrantable(c, data.Scopes); err != nil {
+		return err
+	}
+
+	key, err := h.repos.APIKeys.UpdateScopes(data.ID, data.Scopes)
+	if err != nil {
+		return h.handleAPIKeyError(c, err, "Failed to update API key scopes")
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+type RevokeAPIKeyRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) RevokeAPIKey(c echo.Context) error {
+	data := RevokeAPIKeyRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	key, err := h.repos.APIKeys.Revoke(data.ID)
+	if err != nil {
+		return h.handleAPIKeyError(c, err, "Failed to revoke API key")
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+// checkGrantable rejects scopes the caller does not hold, so that managing API
+// keys does not give a caller more permissions than they have.
+func checkGrantable(c echo.Context, scopes []string) error {
+	var missing []string
+	for _, scope := range scopes {
+		if !auth.Holds(c, auth.Permission(scope)) {
+			missing = append(missing, scope)
+		}
+	}
+
+	if len(missing) > 0 {
+		return problem.Newf(http.StatusForbidden, "Cannot grant scopes you do not hold: %s", strings.Join(missing, ", ")).
+			With("scopes", missing)
+	}
+	return nil
+}
+
+func (h *AdminHandler) handleAPIKeyError(c echo.Context, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, "API key not found")
+	}
+	return problem.Internal(err, message)
+}
+
+func (h *AdminHandler) GetCoupons(c echo.Context) error {
+	coupons, err := h.repos.Coupons.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get coupons")
+	}
+
+	return c.JSON(http.StatusOK, coupons)
+}
+
+// CouponRequest describes a coupon. Value is ignored for free shipping
+// coupons.
+type CouponRequest struct {
+	Code         string            `json:"code" validate:"required,max=50"`
+	Type         models.CouponType `json:"type" validate:"required,oneof=percentage fixed_amount free_shipping"`
+	Value        decimal.Decimal   `json:"value"`
+	MinCartValue decimal.Decimal   `json:"minCartValue"`
+	CategoryIDs  []uint            `json:"categoryIds"`
+	UsageLimit   *int              `json:"usageLimit" validate:"omitempty,min=1"`
+	ValidFrom    *time.Time        `json:"validFrom"`
+	ValidUntil   *time.Time        `json:"validUntil"`
+}
+
+// coupon checks the rules the validator cannot express and builds the coupon.
+func (r CouponRequest) coupon() (*models.Coupon, string) {
+	switch r.Type {
+	case models.CouponPercentage:
+		if !r.Value.IsPositive() || r.Value.GreaterThan(decimal.NewFromInt(100)) {
+			return nil, "Percentage must be between 0 and 100"
+		}
+	case models.CouponFixedAmount:
+		if !r.Value.IsPositive() {
+			return nil, "Amount must be positive"
+		}
+	case models.CouponFreeShipping:
+		r.Value = decimal.Zero
+	}
+
+	if r.MinCartValue.IsNegative() {
+		return nil, "Minimum cart value cannot be negative"
+	}
+
+	if r.ValidFrom != nil && r.ValidUntil != nil && !r.ValidUntil.After(*r.ValidFrom) {
+		return nil, "Coupon must end after it starts"
+	}
+
+	return &models.Coupon{
+		Code:         normalizeCouponCode(r.Code),
+		Type:         r.Type,
+		Value:        r.Value,
+		MinCartValue: r.MinCartValue,
+		CategoryIDs:  r.CategoryIDs,
+		UsageLimit:   r.UsageLimit,
+		ValidFrom:    r.ValidFrom,
+		ValidUntil:   r.ValidUntil,
+	}, ""
+}
+
+func (h *AdminHandler) CreateCoupon(c echo.Context) error {
+	data := CouponRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	coupon, msg := data.coupon()
+	if coupon == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	coupon, err := h.repos.Coupons.Create(coupon)
+	if err != nil {
+		return h.handleCouponError(c, err, "Failed to create coupon")
+	}
+
+	return c.JSON(http.StatusCreated, coupon)
+}
+
+type UpdateCouponRequest struct {
+	ID uint `param:"id" validate:"required"`
+	CouponRequest
+}
+
+func (h *AdminHandler) UpdateCoupon(c echo.Context) error {
+	data := UpdateCouponRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	update, msg := data.coupon()
+	if update == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	coupon, err := h.repos.Coupons.GetByID(data.ID)
+	if err != nil {
+		return h.handleCouponError(c, err, "Failed to update coupon")
+	}
+
+	update.Model = coupon.Model
+	update.UsageCount = coupon.UsageCount
+
+	coupon, err = h.repos.Coupons.Update(update)
+	if err != nil {
+		return h.handleCouponError(c, err, "Failed to update coupon")
+	}
+
+	return c.JSON(http.StatusOK, coupon)
+}
+
+type DeleteCouponRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) DeleteCoupon(c echo.Context) error {
+	data := DeleteCouponRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Coupons.Delete(data.ID); err != nil {
+		return h.handleCouponError(c, err, "Failed to delete coupon")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) handleCouponError(c echo.Context, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, CouponNotFound)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return problem.New(http.StatusConflict, "Coupon code already exists")
+	}
+	return problem.Internal(err, message)
+}
+
+func (h *AdminHandler) GetPromotions(c echo.Context) error {
+	promotions, err := h.repos.Promotions.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get promotions")
+	}
+
+	return c.JSON(http.StatusOK, promotions)
+}
+
+// PromotionRequest describes a promotion rule. Buy X get Y rules use
+// BuyQuantity and FreeQuantity, tiered rules use Tiers.
+type PromotionRequest struct {
+	Name         string                 `json:"name" validate:"required,max=200"`
+	Type         models.PromotionType   `json:"type" validate:"required,oneof=buy_x_get_y tiered_percentage"`
+	CategoryID   *uint                  `json:"categoryId"`
+	BuyQuantity  int                    `json:"buyQuantity" validate:"min=0"`
+	FreeQuantity int                    `json:"freeQuantity" validate:"min=0"`
+	Tiers        []models.PromotionTier `json:"tiers"`
+	// Active defaults to true.
+	Active *bool `json:"active"`
+}
+
+// promotion checks the rules the validator cannot express and builds the
+// promotion.
+func (r PromotionRequest) promotion() (*models.Promotion, string) {
+	promotion := &models.Promotion{
+		Name:       r.Name,
+		Type:       r.Type,
+		CategoryID: r.CategoryID,
+		Active:     r.Active == nil || *r.Active,
+	}
+
+	switch r.Type {
+	case models.PromotionBuyXGetY:
+		if r.FreeQuantity < 1 || r.BuyQuantity <= r.FreeQuantity {
+			return nil, "Buy quantity must be greater than free quantity, which must be at least 1"
+		}
+		promotion.BuyQuantity = r.BuyQuantity
+		promotion.FreeQuantity = r.FreeQuantity
+	case models.PromotionTieredPercentage:
+		if len(r.Tiers) == 0 {
+			return nil, "At least one tier is required"
+		}
+		for _, tier := range r.Tiers {
+			if tier.Threshold.IsNegative() {
+				return nil, "Tier threshold cannot be negative"
+			}
+			if !tier.Percentage.IsPositive() || tier.Percentage.GreaterThan(decimal.NewFromInt(100)) {
+				return nil, "Tier percentage must be between 0 and 100"
+			}
+		}
+		promotion.Tiers = r.Tiers
+	}
+
+	return promotion, ""
+}
+
+func (h *AdminHandler) CreatePromotion(c echo.Context) error {
+	data := PromotionRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	promotion, msg := data.promotion()
+	if promotion == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	promotion, err := h.repos.Promotions.Create(promotion)
+	if err != nil {
+		return h.handlePromotionError(c, err, "Failed to create promotion")
+	}
+
+	return c.JSON(http.StatusCreated, promotion)
+}
+
+type UpdatePromotionRequest struct {
+	ID uint `param:"id" validate:"required"`
+	PromotionRequest
+}
+
+func (h *AdminHandler) UpdatePromotion(c echo.Context) error {
+	data := UpdatePromotionRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	update, msg := data.promotion()
+	if update == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	promotion, err := h.repos.Promotions.GetByID(data.ID)
+	if err != nil {
+		return h.handlePromotionError(c, err, "Failed to update promotion")
+	}
+
+	update.Model = promotion.Model
+
+	promotion, err = h.repos.Promotions.Update(update)
+	if err != nil {
+		return h.handlePromotionError(c, err, "Failed to update promotion")
+	}
+
+	return c.JSON(http.StatusOK, promotion)
+}
+
+type DeletePromotionRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) DeletePromotion(c echo.Context) error {
+	data := DeletePromotionRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Promotions.Delete(data.ID); err != nil {
+		return h.handlePromotionError(c, err, "Failed to delete promotion")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) handlePromotionError(c echo.Context, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, "Promotion not found")
+	}
+	return problem.Internal(err, message)
+}
+
+func (h *AdminHandler) GetTaxClasses(c echo.Context) error {
+	classes, err := h.repos.Taxes.GetClasses()
+	if err != nil {
+		return problem.Internal(err, "Failed to get tax classes")
+	}
+
+	return c.JSON(http.StatusOK, classes)
+}
+
+type CreateTaxClassRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+func (h *AdminHandler) CreateTaxClass(c echo.Context) error {
+	data := CreateTaxClassRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	class, err := h.repos.Taxes.CreateClass(&models.TaxClass{Name: data.Name})
+	if err != nil {
+		return h.handleTaxError(c, err, TaxClassNotFound, "Failed to create tax class")
+	}
+
+	return c.JSON(http.StatusCreated, class)
+}
+
+type UpdateTaxClassRequest struct {
+	ID   uint   `param:"id" validate:"required"`
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+func (h *AdminHandler) UpdateTaxClass(c echo.Context) error {
+	data := UpdateTaxClassRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	class, err := h.repos.Taxes.GetClassByID(data.ID)
+	if err != nil {
+		return h.handleTaxError(c, err, TaxClassNotFound, "Failed to update tax class")
+	}
+
+	class.Name = data.Name
+
+	class, err = h.repos.Taxes.UpdateClass(class)
+	if err != nil {
+		return h.handleTaxError(c, err, TaxClassNotFound, "Failed to update tax class")
+	}
+
+	return c.JSON(http.StatusOK, class)
+}
+
+type DeleteTaxClassRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) DeleteTaxClass(c echo.Context) error {
+	data := DeleteTaxClassRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Taxes.DeleteClass(data.ID); err != nil {
+		return h.handleTaxError(c, err, TaxClassNotFound, "Failed to delete tax class")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) GetTaxRates(c echo.Context) error {
+	rates, err := h.repos.Taxes.GetAllRates()
+	if err != nil {
+		return problem.Internal(err, "Failed to get tax rates")
+	}
+
+	return c.JSON(http.StatusOK, rates)
+}
+
+// TaxRateRequest describes the rate of a tax class in a country, or in a
+// region of it. Rate is a percentage.
+type TaxRateRequest struct {
+	TaxClassID uint            `json:"taxClassId" validate:"required"`
+	Country    string          `json:"country" validate:"required,len=2,alpha"`
+	Region     string          `json:"region" validate:"max=100"`
+	Name       string          `json:"name" validate:"required,max=100"`
+	Rate       decimal.Decimal `json:"rate"`
+}
+
+func (r TaxRateRequest) rate() (*models.TaxRate, string) {
+	if r.Rate.IsNegative() || r.Rate.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		return nil, "Rate must be between 0 and 100"
+	}
+
+	return &models.TaxRate{
+		TaxClassID: r.TaxClassID,
+		Country:    strings.ToUpper(r.Country),
+		Region:     strings.TrimSpace(r.Region),
+		Name:       r.Name,
+		Rate:       r.Rate,
+	}, ""
+}
+
+func (h *AdminHandler) CreateTaxRate(c echo.Context) error {
+	data := TaxRateRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	rate, msg := data.rate()
+	if rate == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	if _, err := h.repos.Taxes.GetClassByID(rate.TaxClassID); err != nil {
+		return h.handleTaxError(c, err, TaxClassNotFound, "Failed to create tax rate")
+	}
+
+	rate, err := h.repos.Taxes.CreateRate(rate)
+	if err != nil {
+		return h.handleTaxError(c, err, TaxRateNotFound, "Failed to create tax rate")
+	}
+
+	return c.JSON(http.StatusCreated, rate)
+}
+
+type UpdateTaxRateRequest struct {
+	ID uint `param:"id" validate:"required"`
+	TaxRateRequest
+}
+
+func (h *AdminHandler) UpdateTaxRate(c echo.Context) error {
+	data := UpdateTaxRateRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	update, msg := data.rate()
+	if update == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	if _, err := h.repos.Taxes.GetClassByID(update.TaxClassID); err != nil {
+		return h.handleTaxError(c, err, TaxClassNotFound, "Failed to update tax rate")
+	}
+
+	rate, err := h.repos.Taxes.GetRateByID(data.ID)
+	if err != nil {
+		return h.handleTaxError(c, err, TaxRateNotFound, "Failed to update tax rate")
+	}
+
+	update.Model = rate.Model
+
+	rate, err = h.repos.Taxes.UpdateRate(update)
+	if err != nil {
+		return h.handleTaxError(c, err, TaxRateNotFound, "Failed to update tax rate")
+	}
+
+	return c.JSON(http.StatusOK, rate)
+}
+
+type DeleteTaxRateRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) DeleteTaxRate(c echo.Context) error {
+	data := DeleteTaxRateRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Taxes.DeleteRate(data.ID); err != nil {
+		return h.handleTaxError(c, err, TaxRateNotFound, "Failed to delete tax rate")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) handleTaxError(c echo.Context, err error, notFound string, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, notFound)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		if notFound == TaxClassNotFound {
+			return problem.New(http.StatusConflict, "Tax class already exists")
+		}
+		return problem.New(http.StatusConflict, "Tax class already has a rate for this location")
+	}
+	return problem.Internal(err, message)
+}
+
+func (h *AdminHandler) GetShippingZones(c echo.Context) error {
+	zones, err := h.repos.Shipping.GetZones()
+	if err != nil {
+		return problem.Internal(err, "Failed to get shipping zones")
+	}
+
+	return c.JSON(http.StatusOK, zones)
+}
+
+// ShippingZoneRequest describes the destinations of a zone. A zone without
+// countries covers every country, postcodes are prefixes.
+type ShippingZoneRequest struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Countries []string `json:"countries" validate:"dive,len=2,alpha"`
+	Postcodes []string `json:"postcodes" validate:"dive,required,max=20"`
+}
+
+func (r ShippingZoneRequest) zone() *models.ShippingZone {
+	zone := &models.ShippingZone{
+		Name:      r.Name,
+		Countries: make([]string, len(r.Countries)),
+		Postcodes: make([]string, len(r.Postcodes)),
+	}
+	for i, country := range r.Countries {
+		zone.Countries[i] = strings.ToUpper(country)
+	}
+	for i, postcode := range r.Postcodes {
+		zone.Postcodes[i] = models.NormalizePostcode(postcode)
+	}
+	return zone
+}
+
+func (h *AdminHandler) CreateShippingZone(c echo.Context) error {
+	data := ShippingZoneRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	zone, err := h.repos.Shipping.CreateZone(data.zone())
+	if err != nil {
+		return h.handleShippingError(c, err, ShippingZoneNotFound, "Failed to create shipping zone")
+	}
+
+	return c.JSON(http.StatusCreated, zone)
+}
+
+type UpdateShippingZoneRequest struct {
+	ID uint `param:"id" validate:"required"`
+	ShippingZoneRequest
+}
+
+func (h *AdminHandler) UpdateShippingZone(c echo.Context) error {
+	data := UpdateShippingZoneRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	zone, err := h.repos.Shipping.GetZoneByID(data.ID)
+	if err != nil {
+		return h.handleShippingError(c, err, ShippingZoneNotFound, "Failed to update shipping zone")
+	}
+
+	update := data.zone()
+	update.Model = zone.Model
+
+	zone, err = h.repos.Shipping.UpdateZone(update)
+	if err != nil {
+		return h.handleShippingError(c, err, ShippingZoneNotFound, "Failed to update shipping zone")
+	}
+
+	return c.JSON(http.StatusOK, zone)
+}
+
+type DeleteShippingZoneRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) DeleteShippingZone(c echo.Context) error {
+	data := DeleteShippingZoneRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Shipping.DeleteZone(data.ID); err != nil {
+		return h.handleShippingError(c, err, ShippingZoneNotFound, "Failed to delete shipping zone")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ShippingMethodRequest describes a shipping method of a zone. Flat methods
+// use Price, weight based and price tiered ones use Tiers.
+type ShippingMethodRequest struct {
+	ZoneID    uint                    `json:"zoneId" validate:"required"`
+	Name      string                  `json:"name" validate:"required,max=100"`
+	Type      models.ShippingRateType `json:"type" validate:"required,oneof=flat weight_based price_tiered"`
+	Price     decimal.Decimal         `json:"price"`
+	Tiers     []models.ShippingTier   `json:"tiers"`
+	MaxWeight decimal.Decimal         `json:"maxWeight"`
+	MaxLength decimal.Decimal         `json:"maxLength"`
+	// Active defaults to true.
+	Active *bool `json:"active"`
+}
+
+// method checks the rules the validator cannot express and builds the
+// method.
+func (r ShippingMethodRequest) method() (*models.ShippingMethod, string) {
+	if r.MaxWeight.IsNegative() || r.MaxLength.IsNegative() {
+		return nil, "Maximum weight and length cannot be negative"
+	}
+
+	method := &models.ShippingMethod{
+		ZoneID:    r.ZoneID,
+		Name:      r.Name,
+		Type:      r.Type,
+		MaxWeight: r.MaxWeight,
+		MaxLength: r.MaxLength,
+		Active:    r.Active == nil || *r.Active,
+	}
+
+	switch r.Type {
+	case models.ShippingFlat:
+		if r.Price.IsNegative() {
+			return nil, "Price cannot be negative"
+		}
+		method.Price = r.Price
+	case models.ShippingWeightBased, models.ShippingPriceTiered:
+		if len(r.Tiers) == 0 {
+			return nil, "At least one tier is required"
+		}
+		for _, tier := range r.Tiers {
+			if tier.Threshold.IsNegative() || tier.Price.IsNegative() {
+				return nil, "Tier threshold and price cannot be negative"
+			}
+		}
+		method.Tiers = r.Tiers
+	}
+
+	return method, ""
+}
+
+func (h *AdminHandler) CreateShippingMethod(c echo.Context) error {
+	data := ShippingMethodRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	method, msg := data.method()
+	if method == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	if _, err := h.repos.Shipping.GetZoneByID(method.ZoneID); err != nil {
+		return h.handleShippingError(c, err, ShippingZoneNotFound, "Failed to create shipping method")
+	}
+
+	method, err := h.repos.Shipping.CreateMethod(method)
+	if err != nil {
+		return h.handleShippingError(c, err, ShippingMethodNotFound, "Failed to create shipping method")
+	}
+
+	return c.JSON(http.StatusCreated, method)
+}
+
+type UpdateShippingMethodRequest struct {
+	ID uint `param:"id" validate:"required"`
+	ShippingMethodRequest
+}
+
+func (h *AdminHandler) UpdateShippingMethod(c echo.Context) error {
+	data := UpdateShippingMethodRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	update, msg := data.method()
+	if update == nil {
+		return problem.New(http.StatusBadRequest, msg)
+	}
+
+	if _, err := h.repos.Shipping.GetZoneByID(update.ZoneID); err != nil {
+		return h.handleShippingError(c, err, ShippingZoneNotFound, "Failed to update shipping method")
+	}
+
+	method, err := h.repos.Shipping.GetMethodByID(data.ID)
+	if err != nil {
+		return h.handleShippingError(c, err, ShippingMethodNotFound, "Failed to update shipping method")
+	}
+
+	update.Model = method.Model
+
+	method, err = h.repos.Shipping.UpdateMethod(update)
+	if err != nil {
+		return h.handleShippingError(c, err, ShippingMethodNotFound, "Failed to update shipping method")
+	}
+
+	return c.JSON(http.StatusOK, method)
+}
+
+type DeleteShippingMethodRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *AdminHandler) DeleteShippingMethod(c echo.Context) error {
+	data := DeleteShippingMethodRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Shipping.DeleteMethod(data.ID); err != nil {
+		return h.handleShippingError(c, err, ShippingMethodNotFound, "Failed to delete shipping method")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) handleShippingError(c echo.Context, err error, notFound string, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, notFound)
+	}
+	return problem.Internal(err, message)
+}
+
+// ExchangeRatesResponse lists the rates together with the currency they
+// convert from.
+type ExchangeRatesResponse struct {
+	Base  string                `json:"base"`
+	Rates []models.ExchangeRate `json:"rates"`
+}
+
+func (h *AdminHandler) GetExchangeRates(c echo.Context) error {
+	rates, err := h.repos.ExchangeRates.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get exchange rates")
+	}
+
+	return c.JSON(http.StatusOK, ExchangeRatesResponse{Base: h.baseCurrency, Rates: rates})
+}
+
+type SetExchangeRatesRequest struct {
+	// Rates maps currency codes to how many units of the currency one unit
+	// of the base currency is worth.
+	Rates map[string]decimal.Decimal `json:"rates" validate:"required,min=1"`
+}
+
+// SetExchangeRates creates or replaces the rates of the given currencies,
+// other rates are kept.
+func (h *AdminHandler) SetExchangeRates(c echo.Context) error {
+	data := SetExchangeRatesRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	rates := make(map[string]decimal.Decimal, len(data.Rates))
+	for code, rate := range data.Rates {
+		rates[strings.ToUpper(code)] = rate
+	}
+
+	var rateErr *pricing.ExchangeRateError
+	if err := pricing.ValidateExchangeRates(h.baseCurrency, rates); errors.As(err, &rateErr) {
+		return problem.Newf(http.StatusBadRequest, rateErr.Format, rateErr.Code).With("currency", rateErr.Code)
+	}
+
+	if _, err := h.repos.ExchangeRates.SetAll(rates); err != nil {
+		return problem.Internal(err, "Failed to set exchange rates")
+	}
+
+	return h.GetExchangeRates(c)
+}
+
+type DeleteExchangeRateRequest struct {
+	Currency string `param:"currency" validate:"required"`
+}
+
+func (h *AdminHandler) DeleteExchangeRate(c echo.Context) error {
+	data := DeleteExchangeRateRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.ExchangeRates.Delete(strings.ToUpper(data.Currency)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, "Exchange rate not found")
+		}
+		return problem.Internal(err, "Failed to delete exchange rate")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) GetPayments(c echo.Context) error {
+	list, err := h.repos.Payments.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get payments")
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+type PaymentRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+// CapturePayment collects an authorized payment. Only needed when payments
+// are not captured automatically.
+func (h *AdminHandler) CapturePayment(c echo.Context) error {
+	data := PaymentRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	payment, err := h.repos.Payments.Capture(data.ID)
+	if err != nil {
+		return h.handlePaymentError(c, err, "Failed to capture payment")
+	}
+
+	return c.JSON(http.StatusOK, payment)
+}
+
+type RefundPaymentRequest struct {
+	ID uint `param:"id" validate:"required"`
+	// Amount defaults to everything not refunded yet.
+	Amount decimal.Decimal `json:"amount"`
+}
+
+func (h *AdminHandler) RefundPayment(c echo.Context) error {
+	data := RefundPaymentRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if data.Amount.IsNegative() {
+		return problem.New(http.StatusBadRequest, "Refund amount cannot be negative")
+	}
+
+	payment, err := h.repos.Payments.Refund(data.ID, data.Amount.Round(pricing.Places))
+	if err != nil {
+		return h.handlePaymentError(c, err, "Failed to refund payment")
+	}
+
+	return c.JSON(http.StatusOK, payment)
+}
+
+// VoidPayment releases a payment that was not captured and cancels its order.
+func (h *AdminHandler) VoidPayment(c echo.Context) error {
+	data := PaymentRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	payment, err := h.repos.Payments.Void(data.ID)
+	if err != nil {
+		return h.handlePaymentError(c, err, "Failed to void payment")
+	}
+
+	return c.JSON(http.StatusOK, payment)
+}
+
+func (h *AdminHandler) handlePaymentError(c echo.Context, err error, message string) error {
+	var operationErr *repositories.InvalidPaymentOperationError
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.New(http.StatusNotFound, PaymentNotFound)
+	case errors.Is(err, repositories.ErrRefundTooLarge):
+		return problem.New(http.StatusUnprocessableEntity, "Refund exceeds what is left of the payment")
+	case errors.As(err, &operationErr):
+		return problem.Newf(http.StatusConflict,
+			"Cannot %s a %s payment", operationErr.Operation, operationErr.Status)
+	}
+	return problem.Internal(err, message)
+}
+
+// normalizeCouponCode makes coupon codes case insensitive.
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+const (
+	FailedCreateAPIKey = "Failed to create API key"
+
+	CouponNotFound   = "Coupon not found"
+	TaxClassNotFound = "Tax class not found"
+	TaxRateNotFound  = "Tax rate not found"
+
+	ShippingZoneNotFound   = "Shipping zone not found"
+	ShippingMethodNotFound = "Shipping method not found"
+
+	PaymentNotFound = "Payment not found"
+)
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"store_backend/auth"
+	"store_backend/environment"
+	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/problem"
+	"store_backend/repositories"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AuthHandler struct {
+	repos        repositories.Repositories
+	env          environment.Environment
+	tokens       *auth.TokenIssuer
+	authenticate echo.MiddlewareFunc
+	mergePolicy  repositories.MergePolicy
+}
+
+func (h *AuthHandler) RegisterRoutes(g *echo.Group) error {
+	authGroup := g.Group("/auth")
+
+	authGroup.POST("/register", h.Register)
+	authGroup.POST("/login", h.Login)
+	authGroup.POST("/refresh", h.Refresh)
+	authGroup.POST("/logout", h.Logout)
+
+	authGroup.GET("/me", h.Me, h.authenticate, auth.RequireUser)
+
+	return nil
+}
+
+// Operations documents the routes registered by RegisterRoutes.
+func (h *AuthHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodPost, Path: "/auth/register", Request: RegisterRequest{}, Response: models.User{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/auth/login", Request: LoginRequest{}, Response: TokenResponse{}},
+		{Method: http.MethodPost, Path: "/auth/refresh", Request: RefreshRequest{}, Response: TokenResponse{}},
+		{Method: http.MethodPost, Path: "/auth/logout", Request: LogoutRequest{}},
+		{Method: http.MethodGet, Path: "/auth/me", Summary: "Get the signed in user", Response: models.User{}},
+	}
+}
+
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Name     string `json:"name"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+func (h *AuthHandler) Register(c echo.Context) error {
+	data := RegisterRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	email := normalizeEmail(data.Email)
+
+	_, err := h.repos.Users.GetByEmail(email)
+	if err == nil {
+		return problem.New(http.StatusConflict, "Email already registered")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.Internal(err, FailedRegister)
+	}
+
+	hash, err := auth.HashPassword(data.Password)
+	if err != nil {
+		return problem.Internal(err, FailedRegister)
+	}
+
+	user, err := h.repos.Users.Create(&models.User{
+		Email:        email,
+		Name:         data.Name,
+		Role:         models.RoleCustomer,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return problem.Internal(err, FailedRegister)
+	}
+
+	return c.JSON(http.StatusCreated, user)
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+	// GuestCartID is a cart filled before signing in, it is merged into the
+	// user's cart. GuestCartToken is the token of the cart.
+	GuestCartID    *uint  `json:"guestCartId"`
+	GuestCartToken string `json:"guestCartToken" validate:"required_with=GuestCartID"`
+}
+
+type TokenResponse struct {
+	TokenType             string       `json:"tokenType"`
+	AccessToken           string       `json:"accessToken"`
+	AccessTokenExpiresAt  time.Time    `json:"accessTokenExpiresAt"`
+	RefreshToken          string       `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time    `json:"refreshTokenExpiresAt"`
+	User                  *models.User `json:"user"`
+	// CartID is the cart to continue with after a guest cart was merged.
+	CartID *uint `json:"cartId,omitempty"`
+}
+
+func (h *AuthHandler) Login(c echo.Context) error {
+	data := LoginRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	user, err := h.repos.Users.GetByEmail(normalizeEmail(data.Email))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.Internal(err, FailedLogin)
+	}
+
+	if user == nil || !auth.CheckPassword(user.PasswordHash, data.Password) {
+		return problem.New(http.StatusUnauthorized, InvalidCredentials)
+	}
+
+	refreshToken, err := auth.NewToken()
+	if err != nil {
+		return problem.Internal(err, FailedLogin)
+	}
+
+	session, err := h.repos.Users.CreateSession(user.ID, auth.HashToken(refreshToken), time.Now().Add(h.env.RefreshTokenTTL))
+	if err != nil {
+		return problem.Internal(err, FailedLogin)
+	}
+
+	res, err := h.tokenResponse(user, session, refreshToken)
+	if err != nil {
+		return problem.Internal(err, FailedLogin)
+	}
+
+	if data.GuestCartID != nil {
+		res.CartID = h.adoptGuestCart(user.ID, *data.GuestCartID, data.GuestCartToken)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// adoptGuestCart merges the guest cart into the user's cart. Signing in still
+// succeeds if that fails, the guest cart is then left as it was.
+func (h *AuthHandler) adoptGuestCart(userID uint, guestCartID uint, token string) *uint {
+	cartID, err := h.repos.Carts.AdoptGuestCart(userID, guestCartID, auth.HashToken(token), h.mergePolicy)
+	if err != nil {
+		log.Printf("error merging guest cart %d: %v", guestCartID, err)
+		return nil
+	}
+	return &cartID
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// Refresh exchanges a refresh token for a new access token. The refresh token
+// is rotated, the old one stops working.
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	data := RefreshRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	refreshToken, err := auth.NewToken()
+	if err != nil {
+		return problem.Internal(err, FailedRefresh)
+	}
+
+	session, err := h.repos.Users.RotateSession(
+		auth.HashToken(data.RefreshToken),
+		auth.HashToken(refreshToken),
+		time.Now().Add(h.env.RefreshTokenTTL),
+	)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusUnauthorized, "Invalid refresh token")
+		}
+		return problem.Internal(err, FailedRefresh)
+	}
+
+	return h.returnTokens(c, session.User, session, refreshToken)
+}
+
+func (h *AuthHandler) Me(c echo.Context) error {
+	return c.JSON(http.StatusOK, auth.CurrentUser(c))
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// Logout revokes the refresh token. Access tokens already issued stay valid
+// until they expire.
+func (h *AuthHandler) Logout(c echo.Context) error {
+	data := LogoutRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Users.DeleteSession(auth.HashToken(data.RefreshToken)); err != nil {
+		return problem.Internal(err, "Failed to log out")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AuthHandler) returnTokens(c echo.Context, user *models.User, session *models.Session, refreshToken string) error {
+	res, err := h.tokenResponse(user, session, refreshToken)
+	if err != nil {
+		return problem.Internal(err, FailedLogin)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AuthHandler) tokenResponse(user *models.User, session *models.Session, refreshToken string) (*TokenResponse, error) {
+	accessToken, expiresAt, err := h.tokens.IssueAccessToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		TokenType:             "Bearer",
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  expiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: session.ExpiresAt,
+		User:                  user,
+	}, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+const (
+	InvalidCredentials = "Invalid email or password"
+	FailedRegister     = "Failed to register"
+	FailedLogin        = "Failed to log in"
+	FailedRefresh      = "Failed to refresh token"
+)
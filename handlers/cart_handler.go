@@ -1,48 +1,115 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"store_backend/auth"
 	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/pricing"
+	"store_backend/problem"
 	"store_backend/repositories"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type CartHandler struct {
-	repos repositories.Repositories
+	repos        repositories.Repositories
+	pricing      *pricing.Calculator
+	authenticate echo.MiddlewareFunc
+	currency     echo.MiddlewareFunc
+	mergePolicy  repositories.MergePolicy
 }
 
-func (h *CartHandler) RegisterRoutes(e *echo.Echo) error {
-	carts := e.Group("/carts")
+// CartTokenHeader carries the token of a guest cart, which has to be sent
+// with every request to the cart.
+const CartTokenHeader = "X-Cart-Token"
 
-	carts.GET("", h.GetCarts)
+// CartResponse is a cart together with its server-side computed totals.
+type CartResponse struct {
+	*models.Cart
+	Totals pricing.Totals `json:"totals"`
+	// Token is the token of a guest cart, only returned when it is created.
+	Token string `json:"token,omitempty"`
+}
+
+func (h *CartHandler) RegisterRoutes(g *echo.Group) error {
+	// Guests may fill a cart without an account, listing carts, merging and
+	// checking out need a signed in user.
+	carts := g.Group("/carts", h.authenticate, auth.Require(auth.PermCartUse), h.currency)
+
+	carts.GET("", h.GetCarts, auth.RequireUser)
 	carts.GET("/:id", h.GetCart)
 	carts.POST("", h.CreateCart)
 	carts.DELETE("/:id", h.DeleteCart)
 
-	carts.POST("/:id/checkout", h.DeleteCart)
+	carts.POST("/:id/checkout", h.Checkout, auth.RequireUser)
+	carts.POST("/:id/merge", h.MergeCart, auth.RequireUser)
+	carts.PUT("/:id/status", h.ChangeCartStatus)
+	carts.GET("/:id/history", h.GetCartHistory)
+	carts.GET("/:id/payments", h.GetCartPayments)
+	carts.PUT("/:id/destination", h.SetDestination)
+	carts.GET("/:id/shipping-options", h.GetShippingOptions)
+	carts.PUT("/:id/shipping", h.SetShippingMethod)
+
+	carts.POST("/:id/coupons", h.AddCoupon)
+	carts.DELETE("/:id/coupons/:code", h.RemoveCoupon)
 
 	cartProducts := carts.Group("/:id/products")
 	cartProducts.GET("", h.GetCartProducts)
 	cartProducts.POST("/:productId", h.AddProductToCart)
+	cartProducts.PUT("/:productId", h.SetProductQuantity)
 	cartProducts.DELETE("/:productId", h.RemoveProductFromCart)
 	cartProducts.DELETE("", h.ClearCart)
 
 	return nil
 }
 
+// Operations documents the routes registered by RegisterRoutes.
+func (h *CartHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/carts", Response: []CartResponse{}},
+		{Method: http.MethodGet, Path: "/carts/:id", Request: GetCartRequest{}, Response: CartResponse{}},
+		{Method: http.MethodPost, Path: "/carts", Response: CartResponse{}, Status: http.StatusCreated,
+			Description: "Guest carts are returned with a token, which has to be sent in the X-Cart-Token header with every request to the cart."},
+		{Method: http.MethodDelete, Path: "/carts/:id", Request: DeleteCartRequest{}},
+		{Method: http.MethodPost, Path: "/carts/:id/checkout", Request: CheckoutRequest{}, Response: models.Order{}, Status: http.StatusCreated,
+			Description: "Responds with 202 Accepted instead while a payment awaits confirmation by the provider."},
+		{Method: http.MethodPost, Path: "/carts/:id/merge", Request: MergeCartRequest{}, Response: CartResponse{}},
+		{Method: http.MethodPut, Path: "/carts/:id/status", Request: ChangeCartStatusRequest{}, Response: CartResponse{}},
+		{Method: http.MethodGet, Path: "/carts/:id/history", Request: GetCartHistoryRequest{}, Response: []models.CartStatusChange{}},
+		{Method: http.MethodGet, Path: "/carts/:id/payments", Request: GetCartPaymentsRequest{}, Response: []models.Payment{}},
+		{Method: http.MethodPut, Path: "/carts/:id/destination", Request: SetDestinationRequest{}, Response: CartResponse{}},
+		{Method: http.MethodGet, Path: "/carts/:id/shipping-options", Request: GetShippingOptionsRequest{}, Response: []pricing.ShippingQuote{}},
+		{Method: http.MethodPut, Path: "/carts/:id/shipping", Request: SetShippingMethodRequest{}, Response: CartResponse{}},
+		{Method: http.MethodPost, Path: "/carts/:id/coupons", Request: AddCouponRequest{}, Response: CartResponse{}},
+		{Method: http.MethodDelete, Path: "/carts/:id/coupons/:code", Request: RemoveCouponRequest{}, Response: CartResponse{}},
+		{Method: http.MethodGet, Path: "/carts/:id/products", Request: GetCartProductsRequest{}, Response: []models.CartItem{}},
+		{Method: http.MethodPost, Path: "/carts/:id/products/:productId", Request: AddProductToCartRequest{}, Response: CartResponse{}},
+		{Method: http.MethodPut, Path: "/carts/:id/products/:productId", Request: SetProductQuantityRequest{}, Response: CartResponse{}},
+		{Method: http.MethodDelete, Path: "/carts/:id/products/:productId", Request: RemoveProductFromCartRequest{}, Response: CartResponse{}},
+		{Method: http.MethodDelete, Path: "/carts/:id/products", Request: ClearCartRequest{}, Response: CartResponse{}},
+	}
+}
+
 func (h *CartHandler) GetCarts(c echo.Context) error {
-	carts, err := h.repos.Carts.GetAll()
+	carts, err := h.repos.Carts.GetAllByUser(auth.CurrentUser(c).ID)
 
 	if err != nil {
-		log.Printf("error getting carts: %v", err)
-		return c.NoContent(501)
+		return problem.Internal(err, "Failed to get carts")
+	}
+
+	res := make([]CartResponse, len(carts))
+	for i := range carts {
+		res[i] = h.cartResponse(c, &carts[i])
 	}
 
-	return c.JSON(http.StatusOK, carts)
+	return c.JSON(http.StatusOK, res)
 }
 
 type GetCartRequest struct {
@@ -51,28 +118,41 @@ type GetCartRequest struct {
 
 func (h *CartHandler) GetCart(c echo.Context) error {
 	req := GetCartRequest{}
-	if err := c.Bind(&req); err != nil {
+	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
-	cart, err := h.checkCartExists(req.ID)
+	cart, err := h.checkCartExists(c, req.ID)
 	if err != nil {
 		return h.handleCartError(c, err, "Failed to get cart")
 	}
 
-	return c.JSON(http.StatusOK, cart)
+	return c.JSON(http.StatusOK, h.cartResponse(c, cart))
 }
 
+// CreateCart creates a cart owned by the signed in user, or a guest cart for
+// callers without an account.
 func (h *CartHandler) CreateCart(c echo.Context) error {
-	cart := &models.Cart{}
+	cart := &models.Cart{UserID: auth.CurrentUserID(c)}
+
+	var token string
+	if cart.UserID == nil {
+		var err error
+		if token, err = auth.NewToken(); err != nil {
+			return problem.Internal(err, "Failed to create cart")
+		}
+		cart.TokenHash = auth.HashToken(token)
+	}
 
 	newCart, err := h.repos.Carts.Create(cart)
 	if err != nil {
-		log.Printf("error creating cart: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, "Failed to create cart")
+		return problem.Internal(err, "Failed to create cart")
 	}
 
-	return c.JSON(http.StatusCreated, newCart)
+	res := h.cartResponse(c, newCart)
+	res.Token = token
+
+	return c.JSON(http.StatusCreated, res)
 }
 
 type DeleteCartRequest struct {
@@ -83,36 +163,438 @@ func (h *CartHandler) DeleteCart(c echo.Context) error {
 	data := DeleteCartRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
-	_, err := h.checkCartExists(data.ID)
+	_, err := h.checkCartExists(c, data.ID)
 	if err != nil {
 		return h.handleCartError(c, err, "Failed to delete cart")
 	}
 
 	err = h.repos.Carts.Delete(data.ID)
 	if err != nil {
-		log.Printf("error deleting cart: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, "Failed to delete cart")
+		return h.handleCartError(c, err, "Failed to delete cart")
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
+type ChangeCartStatusRequest struct {
+	ID     uint              `param:"id" validate:"required"`
+	Status models.CartStatus `json:"status" validate:"required,oneof=active locked checked_out abandoned expired"`
+	Reason string            `json:"reason"`
+}
+
+func (h *CartHandler) ChangeCartStatus(c echo.Context) error {
+	data := ChangeCartStatusRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	// Checking out has to go through Checkout so that an order is created.
+	if data.Status == models.CartCheckedOut {
+		return problem.New(http.StatusConflict, "Use checkout to complete a cart")
+	}
+
+	cart, err := h.checkCartExists(c, data.ID)
+	if err != nil {
+		return h.handleCartError(c, err, "Failed to change cart status")
+	}
+
+	// Only a failed payment reopens a checked out cart.
+	if cart.Status == models.CartCheckedOut {
+		return problem.New(http.StatusConflict, "Checked out carts cannot be reopened")
+	}
+
+	err = h.repos.Carts.Transition(data.ID, data.Status, data.Reason)
+	if err != nil {
+		return h.handleCartError(c, err, "Failed to change cart status")
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Status changed, but failed to retrieve updated cart")
+}
+
+type GetCartHistoryRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *CartHandler) GetCartHistory(c echo.Context) error {
+	data := GetCartHistoryRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to get cart history")
+	}
+
+	history, err := h.repos.Carts.GetHistory(data.ID)
+	if err != nil {
+		return h.handleCartError(c, err, "Failed to get cart history")
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
+
+type GetCartPaymentsRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+// GetCartPayments lists every attempt to pay for the cart, including
+// declined ones.
+func (h *CartHandler) GetCartPayments(c echo.Context) error {
+	data := GetCartPaymentsRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to get cart payments")
+	}
+
+	list, err := h.repos.Payments.GetByCart(data.ID)
+	if err != nil {
+		return h.handleCartError(c, err, "Failed to get cart payments")
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+type SetDestinationRequest struct {
+	ID uint `param:"id" validate:"required"`
+	// AddressID copies the destination from an address book entry instead.
+	AddressID *uint  `json:"addressId"`
+	Country   string `json:"country" validate:"required_without=AddressID,omitempty,len=2,alpha"`
+	Region    string `json:"region" validate:"max=100"`
+	Postcode  string `json:"postcode" validate:"max=20"`
+}
+
+// SetDestination sets the country, region and postcode the cart is delivered
+// to, which decide the tax rates it is priced with and the shipping methods on
+// offer.
+func (h *CartHandler) SetDestination(c echo.Context) error {
+	data := SetDestinationRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to set cart destination")
+	}
+
+	country := strings.ToUpper(data.Country)
+	region := strings.TrimSpace(data.Region)
+	postcode := strings.ToUpper(strings.TrimSpace(data.Postcode))
+
+	if data.AddressID != nil {
+		address, err := findOwnAddress(c, h.repos.Addresses, *data.AddressID)
+		if err != nil {
+			return h.handleAddressError(c, err)
+		}
+		country, region, postcode = address.Country, address.Region, address.Postcode
+	}
+
+	if err := h.repos.Carts.SetDestination(data.ID, country, region, postcode); err != nil {
+		return h.handleCartError(c, err, "Failed to set cart destination")
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Destination set, but failed to retrieve updated cart")
+}
+
+type GetShippingOptionsRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+// GetShippingOptions quotes the shipping methods that can deliver the cart to
+// its destination, in the request currency.
+func (h *CartHandler) GetShippingOptions(c echo.Context) error {
+	data := GetShippingOptionsRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	cart, err := h.checkCartExists(c, data.ID)
+	if err != nil {
+		return h.handleCartError(c, err, "Failed to get shipping options")
+	}
+
+	return c.JSON(http.StatusOK, h.pricing.ShippingOptions(cart, currencyOf(c)))
+}
+
+type SetShippingMethodRequest struct {
+	ID uint `param:"id" validate:"required"`
+	// MethodID is one of the cart's shipping options, null clears it.
+	MethodID *uint `json:"methodId"`
+}
+
+func (h *CartHandler) SetShippingMethod(c echo.Context) error {
+	data := SetShippingMethodRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	cart, err := h.checkCartExists(c, data.ID)
+	if err != nil {
+		return h.handleCartError(c, err, FailedSetShipping)
+	}
+
+	if data.MethodID != nil {
+		options := h.pricing.ShippingOptions(cart, currencyOf(c))
+
+		offered := slices.ContainsFunc(options, func(quote pricing.ShippingQuote) bool {
+			return quote.MethodID == *data.MethodID
+		})
+		if !offered {
+			return problem.New(http.StatusUnprocessableEntity, ShippingMethodUnavailable)
+		}
+	}
+
+	if err := h.repos.Carts.SetShippingMethod(data.ID, data.MethodID); err != nil {
+		return h.handleCartError(c, err, FailedSetShipping)
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Shipping method set, but failed to retrieve updated cart")
+}
+
+type CheckoutRequest struct {
+	ID uint `param:"id" validate:"required"`
+	// Addresses are given either by the ID of an address book entry or in
+	// full. The shipping address defaults to the default shipping address,
+	// the billing address to the default billing address and then to the
+	// shipping address.
+	ShippingAddressID *uint           `json:"shippingAddressId"`
+	ShippingAddress   *AddressRequest `json:"shippingAddress"`
+	BillingAddressID  *uint           `json:"billingAddressId"`
+	BillingAddress    *AddressRequest `json:"billingAddress"`
+	// PaymentMethod is the token of the payment method from the payment
+	// provider, required unless the order is free.
+	PaymentMethod string `json:"paymentMethod" validate:"max=200"`
+}
+
+// Checkout creates an order from the cart contents and closes the cart. The
+// cart is delivered to the shipping address.
+//
+// The order is paid for right away. If the payment needs the customer to
+// confirm it with their bank, the order is accepted with 202 and waits for
+// payment; the payment's actionUrl is where the customer confirms it.
+func (h *CartHandler) Checkout(c echo.Context) error {
+	data := CheckoutRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to check out cart")
+	}
+
+	shipping, err := h.checkoutAddress(c, data.ShippingAddressID, data.ShippingAddress, h.repos.Addresses.GetDefaultShipping)
+	if err != nil {
+		return h.handleAddressError(c, err)
+	}
+	if shipping == nil {
+		return problem.New(http.StatusUnprocessableEntity, "Shipping address required")
+	}
+
+	billing, err := h.checkoutAddress(c, data.BillingAddressID, data.BillingAddress, h.repos.Addresses.GetDefaultBilling)
+	if err != nil {
+		return h.handleAddressError(c, err)
+	}
+	if billing == nil {
+		billing = shipping
+	}
+
+	checkout := repositories.Checkout{
+		UserID:          auth.CurrentUser(c).ID,
+		Currency:        currencyOf(c),
+		ShippingAddress: *shipping,
+		BillingAddress:  *billing,
+		PaymentMethod:   data.PaymentMethod,
+	}
+
+	order, err := h.repos.Orders.CreateFromCart(data.ID, checkout)
+	if err != nil {
+		var paymentErr *repositories.PaymentError
+		if errors.As(err, &paymentErr) {
+			if paymentErr.Payment.Status == models.PaymentDeclined {
+				return problem.Newf(http.StatusPaymentRequired,
+					"Payment declined: %s", paymentErr.Payment.FailureReason)
+			}
+			log.Printf("error taking payment: %v", err)
+			return problem.New(http.StatusBadGateway, "Payment provider unavailable")
+		}
+		if errors.Is(err, repositories.ErrCartEmpty) {
+			return problem.New(http.StatusUnprocessableEntity, "Cart is empty")
+		}
+		if errors.Is(err, repositories.ErrShippingMethodRequired) {
+			return problem.New(http.StatusUnprocessableEntity, "Choose a shipping method first")
+		}
+		if errors.Is(err, repositories.ErrShippingMethodUnavailable) {
+			return problem.New(http.StatusUnprocessableEntity, ShippingMethodUnavailable)
+		}
+		if errors.Is(err, repositories.ErrPaymentMethodRequired) {
+			return problem.New(http.StatusUnprocessableEntity, "Choose a payment method")
+		}
+		return h.handleCartError(c, err, "Failed to check out cart")
+	}
+
+	for _, payment := range order.Payments {
+		if payment.Status == models.PaymentPending {
+			return c.JSON(http.StatusAccepted, order)
+		}
+	}
+
+	return c.JSON(http.StatusCreated, order)
+}
+
+// checkoutAddress returns the address given by ID or in full, or else the
+// caller's default address. It returns nil if there is no address at all.
+func (h *CartHandler) checkoutAddress(c echo.Context, id *uint, inline *AddressRequest, fallback func(userID uint) (*models.Address, error)) (*models.PostalAddress, error) {
+	switch {
+	case id != nil:
+		address, err := findOwnAddress(c, h.repos.Addresses, *id)
+		if err != nil {
+			return nil, err
+		}
+		return &address.PostalAddress, nil
+	case inline != nil:
+		address, err := inline.postalAddress()
+		if err != nil {
+			return nil, err
+		}
+		return &address, nil
+	}
+
+	address, err := fallback(auth.CurrentUser(c).ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &address.PostalAddress, nil
+}
+
+// handleAddressError reports why an address could not be used: it is invalid
+// or its ID is unknown.
+func (h *CartHandler) handleAddressError(c echo.Context, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, AddressNotFound)
+	}
+	var p *problem.Details
+	if errors.As(err, &p) {
+		return err
+	}
+	return problem.Internal(err, "Failed to get address")
+}
+
+type MergeCartRequest struct {
+	ID           uint `param:"id" validate:"required"`
+	SourceCartID uint `json:"sourceCartId" validate:"required"`
+	// Policy defaults to the configured cart merge policy.
+	Policy repositories.MergePolicy `json:"policy" validate:"omitempty,oneof=merge replace keep_newest"`
+}
+
+// MergeCart moves the contents of another cart, usually a guest cart, into
+// this one. The source cart is closed afterwards.
+func (h *CartHandler) MergeCart(c echo.Context) error {
+	data := MergeCartRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if data.Policy == "" {
+		data.Policy = h.mergePolicy
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, FailedMergeCarts)
+	}
+
+	if _, err := h.checkCartExists(c, data.SourceCartID); err != nil {
+		return h.handleCartError(c, err, FailedMergeCarts)
+	}
+
+	err := h.repos.Carts.Merge(data.ID, data.SourceCartID, data.Policy)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMergeSameCart) {
+			return problem.New(http.StatusUnprocessableEntity, "Cannot merge a cart into itself")
+		}
+		return h.handleCartError(c, err, FailedMergeCarts)
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Carts merged, but failed to retrieve updated cart")
+}
+
+type AddCouponRequest struct {
+	ID   uint   `param:"id" validate:"required"`
+	Code string `json:"code" validate:"required"`
+}
+
+func (h *CartHandler) AddCoupon(c echo.Context) error {
+	data := AddCouponRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, FailedApplyCoupon)
+	}
+
+	err := h.repos.Carts.AddCoupon(data.ID, normalizeCouponCode(data.Code))
+	if err != nil {
+		return h.handleCartError(c, err, FailedApplyCoupon)
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Coupon applied, but failed to retrieve updated cart")
+}
+
+type RemoveCouponRequest struct {
+	ID   uint   `param:"id" validate:"required"`
+	Code string `param:"code" validate:"required"`
+}
+
+func (h *CartHandler) RemoveCoupon(c echo.Context) error {
+	data := RemoveCouponRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to remove coupon")
+	}
+
+	err := h.repos.Carts.RemoveCoupon(data.ID, normalizeCouponCode(data.Code))
+	if err != nil {
+		return h.handleCartError(c, err, "Failed to remove coupon")
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Coupon removed, but failed to retrieve updated cart")
+}
+
 type AddProductToCartRequest struct {
 	ID        uint `param:"id" validate:"required"`
 	ProductID uint `param:"productId" validate:"required"`
+	// Quantity is added to the quantity already in the cart, defaults to 1.
+	Quantity int `json:"quantity" validate:"omitempty,min=1"`
 }
 
 func (h *CartHandler) AddProductToCart(c echo.Context) error {
 	data := AddProductToCartRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
-	_, err := h.checkCartExists(data.ID)
+	_, err := h.checkCartExists(c, data.ID)
 	if err != nil {
 		return h.handleCartError(c, err, FailedAddToCart)
 	}
@@ -120,42 +602,80 @@ func (h *CartHandler) AddProductToCart(c echo.Context) error {
 	err = h.checkProductExists(data.ProductID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return h.returnErrorJSON(c, http.StatusNotFound, "Product not found")
+			return problem.New(http.StatusNotFound, "Product not found")
 		}
-		log.Printf("error getting product: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, FailedAddToCart)
+		return problem.Internal(err, FailedAddToCart)
+	}
+
+	if data.Quantity == 0 {
+		data.Quantity = 1
 	}
 
-	err = h.repos.Carts.AddProduct(data.ID, data.ProductID)
+	err = h.repos.Carts.AddProduct(data.ID, data.ProductID, data.Quantity)
 	if err != nil {
-		log.Printf("error adding product to cart: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, FailedAddToCart)
+		return h.handleCartError(c, err, FailedAddToCart)
 	}
 
 	return h.returnUpdatedCart(c, data.ID, "Product added, but failed to retrieve updated cart")
 }
 
+type SetProductQuantityRequest struct {
+	ID        uint `param:"id" validate:"required"`
+	ProductID uint `param:"productId" validate:"required"`
+	// Quantity replaces the quantity in the cart, 0 removes the product.
+	Quantity *int `json:"quantity" validate:"required,min=0"`
+}
+
+func (h *CartHandler) SetProductQuantity(c echo.Context) error {
+	data := SetProductQuantityRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	_, err := h.checkCartExists(c, data.ID)
+	if err != nil {
+		return h.handleCartError(c, err, FailedUpdateQuantity)
+	}
+
+	err = h.repos.Carts.SetProductQuantity(data.ID, data.ProductID, *data.Quantity)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, "Product not found")
+		}
+		return h.handleCartError(c, err, FailedUpdateQuantity)
+	}
+
+	return h.returnUpdatedCart(c, data.ID, "Quantity updated, but failed to retrieve updated cart")
+}
+
 type RemoveProductFromCartRequest struct {
 	ID        uint `param:"id" validate:"required"`
 	ProductID uint `param:"productId" validate:"required"`
+	// All removes the whole line instead of a single unit.
+	All bool `query:"all"`
 }
 
 func (h *CartHandler) RemoveProductFromCart(c echo.Context) error {
 	data := RemoveProductFromCartRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
-	_, err := h.checkCartExists(data.ID)
+	_, err := h.checkCartExists(c, data.ID)
 	if err != nil {
 		return h.handleCartError(c, err, "Failed to remove product from cart")
 	}
 
-	err = h.repos.Carts.RemoveProduct(data.ID, data.ProductID)
+	quantity := 1
+	if data.All {
+		quantity = 0
+	}
+
+	err = h.repos.Carts.RemoveProduct(data.ID, data.ProductID, quantity)
 	if err != nil {
-		log.Printf("error removing product from cart: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, "Failed to remove product from cart")
+		return h.handleCartError(c, err, "Failed to remove product from cart")
 	}
 
 	return h.returnUpdatedCart(c, data.ID, "Product removed, but failed to retrieve updated cart")
@@ -169,20 +689,25 @@ func (h *CartHandler) GetCartProducts(c echo.Context) error {
 	data := GetCartProductsRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to get cart products")
 	}
 
-	products, err := h.repos.Carts.GetProducts(data.ID)
+	items, err := h.repos.Carts.GetItems(data.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return h.returnErrorJSON(c, http.StatusNotFound, CartNotFound)
+			return problem.New(http.StatusNotFound, CartNotFound)
 		}
 
-		log.Printf("error getting cart products: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, "Failed to get cart products")
+		return problem.Internal(err, "Failed to get cart products")
 	}
 
-	return c.JSON(http.StatusOK, products)
+	localizeItems(c, items)
+
+	return c.JSON(http.StatusOK, items)
 }
 
 type ClearCartRequest struct {
@@ -193,24 +718,48 @@ func (h *CartHandler) ClearCart(c echo.Context) error {
 	data := ClearCartRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
+	}
+
+	if _, err := h.checkCartExists(c, data.ID); err != nil {
+		return h.handleCartError(c, err, "Failed to clear cart")
 	}
 
 	err := h.repos.Carts.ClearCart(data.ID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return h.returnErrorJSON(c, http.StatusNotFound, CartNotFound)
-		}
-
-		log.Printf("error clearing cart: %v", err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, "Failed to clear cart")
+		return h.handleCartError(c, err, "Failed to clear cart")
 	}
 
 	return h.returnUpdatedCart(c, data.ID, "Cart cleared, but failed to retrieve updated cart")
 }
 
-func (h *CartHandler) checkCartExists(id uint) (*models.Cart, error) {
-	return h.repos.Carts.GetByID(id)
+// checkCartExists loads the cart, reporting carts the caller may not use as
+// not found.
+func (h *CartHandler) checkCartExists(c echo.Context, id uint) (*models.Cart, error) {
+	cart, err := h.repos.Carts.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !mayUseCart(c, cart) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return cart, nil
+}
+
+// mayUseCart reports whether the caller may use the cart: carts of users are
+// used by their owner only, guest carts by whoever sends their token.
+func mayUseCart(c echo.Context, cart *models.Cart) bool {
+	if cart.UserID != nil {
+		return ownedByCaller(c, cart.UserID)
+	}
+
+	token := c.Request().Header.Get(CartTokenHeader)
+	if token == "" || cart.TokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth.HashToken(token)), []byte(cart.TokenHash)) == 1
 }
 
 func (h *CartHandler) checkProductExists(id uint) error {
@@ -219,32 +768,65 @@ func (h *CartHandler) checkProductExists(id uint) error {
 }
 
 func (h *CartHandler) handleCartError(c echo.Context, err error, message string) error {
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return h.returnErrorJSON(c, http.StatusNotFound, CartNotFound)
+	var transitionErr *repositories.InvalidCartTransitionError
+	var stockErr *repositories.InsufficientStockError
+	var couponErr *repositories.CouponUsedUpError
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.New(http.StatusNotFound, CartNotFound)
+	case errors.Is(err, repositories.ErrProductNotInCart):
+		return problem.New(http.StatusNotFound, ProductNotInCart)
+	case errors.Is(err, repositories.ErrCartNotActive):
+		return problem.New(http.StatusConflict, CartNotActive)
+	case errors.Is(err, repositories.ErrCouponNotFound):
+		return problem.New(http.StatusNotFound, CouponNotFound)
+	case errors.Is(err, repositories.ErrCouponNotInCart):
+		return problem.New(http.StatusNotFound, "Coupon not applied to cart")
+	case errors.Is(err, repositories.ErrCouponNotUsable):
+		return problem.New(http.StatusUnprocessableEntity, "Coupon is not valid at this time")
+	case errors.As(err, &couponErr):
+		return problem.Newf(http.StatusConflict,
+			"Coupon %s is no longer available", couponErr.Code)
+	case errors.As(err, &stockErr):
+		return problem.Newf(http.StatusConflict,
+			"Only %d of product %d in stock", stockErr.Available, stockErr.ProductID)
+	case errors.As(err, &transitionErr):
+		return problem.Newf(http.StatusConflict,
+			"Cannot change cart status from %s to %s", transitionErr.From, transitionErr.To)
 	}
-	log.Printf(ErrGettingCart, err)
-	return h.returnErrorJSON(c, http.StatusInternalServerError, message)
-}
-
-func (h *CartHandler) returnErrorJSON(c echo.Context, status int, message string) error {
-	return c.JSON(status, map[string]string{
-		"error": message,
-	})
+	return problem.Internal(err, message)
 }
 
 func (h *CartHandler) returnUpdatedCart(c echo.Context, id uint, errorMessage string) error {
 	cart, err := h.repos.Carts.GetByID(id)
 	if err != nil {
-		log.Printf(ErrUpdatedCart, err)
-		return h.returnErrorJSON(c, http.StatusInternalServerError, errorMessage)
+		return problem.Internal(err, errorMessage)
 	}
-	return c.JSON(http.StatusOK, cart)
+	return c.JSON(http.StatusOK, h.cartResponse(c, cart))
+}
+
+// cartResponse prices the cart in the request currency. Item prices and the
+// products in the cart are shown in that currency too.
+func (h *CartHandler) cartResponse(c echo.Context, cart *models.Cart) CartResponse {
+	totals := h.pricing.Calculate(cart, currencyOf(c))
+	localizeItems(c, cart.Items)
+
+	return CartResponse{Cart: cart, Totals: totals}
 }
 
 const (
 	ErrGettingCart = "error getting cart: %v"
 	ErrUpdatedCart = "error getting updated cart: %v"
 
-	CartNotFound    = "Cart not found"
-	FailedAddToCart = "Failed to add product to cart"
+	CartNotFound         = "Cart not found"
+	CartNotActive        = "Cart can no longer be modified"
+	ProductNotInCart     = "Product not in cart"
+	FailedAddToCart      = "Failed to add product to cart"
+	FailedUpdateQuantity = "Failed to update product quantity"
+	FailedMergeCarts     = "Failed to merge carts"
+	FailedApplyCoupon    = "Failed to apply coupon"
+	FailedSetShipping    = "Failed to set shipping method"
+
+	ShippingMethodUnavailable = "Shipping method is not available for this cart"
 )
@@ -1,15 +1,234 @@
 package handlers
 
 import (
+	"errors"
+	"net/http"
+	"store_backend/auth"
+	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/problem"
 	"store_backend/repositories"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type CategoriesHandler struct {
-	repos repositories.Repositories
+	repos         repositories.Repositories
+	authenticate  echo.MiddlewareFunc
+	currency      echo.MiddlewareFunc
+	defaultLocale string
 }
 
-func (h *CategoriesHandler) RegisterRoutes(e *echo.Echo) error {
+func (h *CategoriesHandler) RegisterRoutes(g *echo.Group) error {
+	categories := g.Group("/categories", h.authenticate)
+
+	read := auth.Require(auth.PermCatalogRead)
+	write := auth.Require(auth.PermCatalogWrite)
+
+	categories.GET("", h.GetCategories, read)
+	categories.GET("/:id", h.GetCategory, read)
+	categories.POST("", h.CreateCategory, write)
+	categories.PUT("/:id", h.UpdateCategory, write)
+	categories.DELETE("/:id", h.DeleteCategory, write)
+
+	categories.GET("/:id/products", h.GetCategoryProducts, read, h.currency)
+
+	categories.PUT("/:id/translations/:locale", h.SetTranslation, write)
+	categories.DELETE("/:id/translations/:locale", h.DeleteTranslation, write)
+
 	return nil
 }
+
+// Operations documents the routes registered by RegisterRoutes.
+func (h *CategoriesHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/categories", Response: []models.Category{}},
+		{Method: http.MethodGet, Path: "/categories/:id", Request: GetCategoryRequest{}, Response: models.Category{}},
+		{Method: http.MethodPost, Path: "/categories", Request: CreateCategoryRequest{}, Response: models.Category{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/categories/:id", Request: UpdateCategoryRequest{}, Response: models.Category{}},
+		{Method: http.MethodDelete, Path: "/categories/:id", Request: DeleteCategoryRequest{}},
+		{Method: http.MethodGet, Path: "/categories/:id/products", Request: GetCategoryProductsRequest{}, Response: []models.Product{}},
+		{Method: http.MethodPut, Path: "/categories/:id/translations/:locale", Request: SetTranslationRequest{}, Response: models.Category{}},
+		{Method: http.MethodDelete, Path: "/categories/:id/translations/:locale", Request: DeleteTranslationRequest{}},
+	}
+}
+
+func (h *CategoriesHandler) GetCategories(c echo.Context) error {
+	categories, err := h.repos.Categories.GetAll()
+	if err != nil {
+		return problem.Internal(err, "Failed to get categories")
+	}
+
+	localizeCategories(c, categories)
+
+	return c.JSON(http.StatusOK, categories)
+}
+
+type GetCategoryRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *CategoriesHandler) GetCategory(c echo.Context) error {
+	data := GetCategoryRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	category, err := h.repos.Categories.GetByID(data.ID)
+	if err != nil {
+		return h.handleCategoryError(c, err, "Failed to get category")
+	}
+
+	localizeCategory(c, category)
+
+	return c.JSON(http.StatusOK, category)
+}
+
+type CreateCategoryRequest struct {
+	Name       string `json:"name" validate:"required,max=200"`
+	TaxClassID *uint  `json:"taxClassId"`
+}
+
+func (h *CategoriesHandler) CreateCategory(c echo.Context) error {
+	data := CreateCategoryRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	category, err := h.repos.Categories.Create(&models.Category{Name: data.Name, TaxClassID: data.TaxClassID})
+	if err != nil {
+		return problem.Internal(err, "Failed to create category")
+	}
+
+	return c.JSON(http.StatusCreated, category)
+}
+
+type UpdateCategoryRequest struct {
+	ID         uint   `param:"id" validate:"required"`
+	Name       string `json:"name" validate:"required,max=200"`
+	TaxClassID *uint  `json:"taxClassId"`
+}
+
+func (h *CategoriesHandler) UpdateCategory(c echo.Context) error {
+	data := UpdateCategoryRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	category, err := h.repos.Categories.GetByID(data.ID)
+	if err != nil {
+		return h.handleCategoryError(c, err, "Failed to update category")
+	}
+
+	category.Name = data.Name
+	category.TaxClassID = data.TaxClassID
+
+	category, err = h.repos.Categories.Update(category)
+	if err != nil {
+		return problem.Internal(err, "Failed to update category")
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+type DeleteCategoryRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+// DeleteCategory removes the category. Products assigned to it are kept and
+// become uncategorized.
+func (h *CategoriesHandler) DeleteCategory(c echo.Context) error {
+	data := DeleteCategoryRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	if err := h.repos.Categories.Delete(data.ID); err != nil {
+		return h.handleCategoryError(c, err, "Failed to delete category")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type GetCategoryProductsRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *CategoriesHandler) GetCategoryProducts(c echo.Context) error {
+	data := GetCategoryProductsRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	products, err := h.repos.Categories.GetProducts(data.ID)
+	if err != nil {
+		return h.handleCategoryError(c, err, "Failed to get category products")
+	}
+
+	localizeProducts(c, products)
+
+	return c.JSON(http.StatusOK, products)
+}
+
+// SetTranslation sets the name of the category in a locale other than the
+// default locale.
+func (h *CategoriesHandler) SetTranslation(c echo.Context) error {
+	data := SetTranslationRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	locale, err := translationLocale(data.Locale, h.defaultLocale)
+	if err != nil {
+		return err
+	}
+
+	if err := h.repos.Categories.SetTranslation(data.ID, locale, data.Name); err != nil {
+		return h.handleCategoryError(c, err, "Failed to set translation")
+	}
+
+	category, err := h.repos.Categories.GetByID(data.ID)
+	if err != nil {
+		return h.handleCategoryError(c, err, "Failed to get category")
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoriesHandler) DeleteTranslation(c echo.Context) error {
+	data := DeleteTranslationRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	err := h.repos.Categories.DeleteTranslation(data.ID, strings.ToLower(data.Locale))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, TranslationNotFound)
+		}
+		return problem.Internal(err, "Failed to delete translation")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *CategoriesHandler) handleCategoryError(c echo.Context, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, CategoryNotFound)
+	}
+	return problem.Internal(err, message)
+}
+
+const (
+	CategoryNotFound    = "Category not found"
+	TranslationNotFound = "Translation not found"
+)
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"store_backend/i18n"
+	"store_backend/models"
+	"store_backend/pricing"
+	"store_backend/problem"
+	"store_backend/repositories"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CurrencyHeader can be sent instead of the currency query parameter.
+const CurrencyHeader = "X-Currency"
+
+const currencyContextKey = "currency"
+
+// negotiateCurrency picks the currency prices are shown in from the currency
+// query parameter or the X-Currency header. Without either the base currency
+// is used, a currency without an exchange rate is rejected.
+func negotiateCurrency(rates *repositories.ExchangeRateRepository, base string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			code := c.QueryParam("currency")
+			if code == "" {
+				code = c.Request().Header.Get(CurrencyHeader)
+			}
+			code = strings.ToUpper(strings.TrimSpace(code))
+
+			currency := pricing.BaseCurrency(base)
+
+			if code != "" && code != base {
+				rate, err := rates.Get(code)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return problem.Newf(http.StatusBadRequest, "Unsupported currency %s", code)
+				} else if err != nil {
+					return problem.Internal(err, "Failed to get exchange rate")
+				}
+
+				currency = pricing.NewCurrency(rate.Currency, rate.Rate, base)
+			}
+
+			c.Set(currencyContextKey, currency)
+			return next(c)
+		}
+	}
+}
+
+// currencyOf returns the currency chosen by negotiateCurrency.
+func currencyOf(c echo.Context) pricing.Currency {
+	currency, _ := c.Get(currencyContextKey).(pricing.Currency)
+	return currency
+}
+
+// localizeProducts shows the prices of the products in the request currency
+// and their names in the request locale.
+func localizeProducts(c echo.Context, products []models.Product) {
+	for i := range products {
+		localizeProduct(c, &products[i])
+	}
+}
+
+func localizeProduct(c echo.Context, product *models.Product) {
+	currency := currencyOf(c)
+
+	product.Price = currency.PriceOf(product)
+	product.Currency = currency.Code
+	product.Name = product.NameIn(i18n.Locale(c))
+}
+
+// localizeItems shows the prices of cart lines and their products in the
+// request currency.
+func localizeItems(c echo.Context, items []models.CartItem) {
+	currency := currencyOf(c)
+
+	for i := range items {
+		item := &items[i]
+		item.UnitPrice = currency.UnitPriceOf(*item)
+		if item.Product != nil {
+			localizeProduct(c, item.Product)
+		}
+	}
+}
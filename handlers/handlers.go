@@ -1,36 +1,90 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
+	"store_backend/auth"
+	"store_backend/environment"
+	"store_backend/openapi"
+	"store_backend/pricing"
+	"store_backend/problem"
 	"store_backend/repositories"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
 type Handler interface {
-	RegisterRoutes(e *echo.Echo) error
+	// RegisterRoutes registers the routes of the handler in the group of an
+	// API version.
+	RegisterRoutes(g *echo.Group) error
+	// Operations documents every route the handler registers, the server
+	// refuses to start with undocumented routes.
+	Operations() []openapi.Operation
 }
 
-func Initialize(repos repositories.Repositories) []Handler {
-	return []Handler{
-		&ProductsHandler{repos: repos},
-		&CategoriesHandler{repos: repos},
-		&CartHandler{repos: repos},
+// Version is a set of handlers served under /<Name>, e.g. /v1. Versions are
+// served side by side: a breaking change goes into a new version, which lists
+// all of its handlers, reusing the unchanged ones, while clients move over
+// from the old version.
+type Version struct {
+	Name     string
+	Handlers []Handler
+	// Deprecated and Sunset deprecate every route of the version, see
+	// openapi.Operation.
+	Deprecated time.Time
+	Sunset     time.Time
+}
+
+func Initialize(repos repositories.Repositories, env environment.Environment) []Version {
+	calculator := pricing.NewCalculator(env, repos.Promotions, repos.Taxes, repos.Shipping)
+	tokens := auth.NewTokenIssuer(env)
+	authenticate := auth.Authenticate(tokens, env.APITokens, repos.Users)
+
+	currency := negotiateCurrency(repos.ExchangeRates, env.BaseCurrency)
+
+	mergePolicy := repositories.MergePolicy(env.CartMergePolicy)
+	if !mergePolicy.Valid() {
+		panic(fmt.Errorf("invalid cart merge policy: %s", env.CartMergePolicy))
+	}
+
+	v1 := []Handler{
+		&ProductsHandler{repos: repos, authenticate: authenticate, currency: currency, baseCurrency: env.BaseCurrency, defaultLocale: env.DefaultLocale},
+		&CategoriesHandler{repos: repos, authenticate: authenticate, currency: currency, defaultLocale: env.DefaultLocale},
+		&CartHandler{repos: repos, pricing: calculator, authenticate: authenticate, currency: currency, mergePolicy: mergePolicy},
+		&OrdersHandler{repos: repos, authenticate: authenticate},
+		&AddressHandler{repos: repos, authenticate: authenticate},
+		&PaymentsHandler{repos: repos},
+		&AuthHandler{repos: repos, env: env, tokens: tokens, authenticate: authenticate, mergePolicy: mergePolicy},
+		&AdminHandler{repos: repos, authenticate: authenticate, baseCurrency: env.BaseCurrency},
+	}
+
+	return []Version{
+		{Name: "v1", Handlers: v1},
 	}
 }
 
-func bindAndValidate(c echo.Context, model interface{}) map[string]string {
+// bindAndValidate binds the request to model and validates it, returning a
+// problem that lists the fields that are wrong.
+func bindAndValidate(c echo.Context, model interface{}) error {
 	if err := c.Bind(model); err != nil {
 		log.Printf("Binding error: %v", err)
 
-		return map[string]string{"error": "Invalid request format"}
+		return problem.FromBinding(err)
 	}
 
 	if err := c.Validate(model); err != nil {
 		log.Printf("Validation error: %v", err)
 
-		return map[string]string{"error": "Invalid request format"}
+		return err
 	}
 
 	return nil
 }
+
+// ownedByCaller reports whether the signed in user owns a resource with the
+// given owner. Resources without an owner are owned by no one.
+func ownedByCaller(c echo.Context, ownerID *uint) bool {
+	user := auth.CurrentUser(c)
+	return ownerID != nil && user != nil && user.ID == *ownerID
+}
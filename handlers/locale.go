@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"store_backend/i18n"
+	"store_backend/models"
+	"store_backend/problem"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// localizeCategories shows the names of the categories in the request locale.
+func localizeCategories(c echo.Context, categories []models.Category) {
+	for i := range categories {
+		localizeCategory(c, &categories[i])
+	}
+}
+
+func localizeCategory(c echo.Context, category *models.Category) {
+	category.Name = category.NameIn(i18n.Locale(c))
+}
+
+type SetTranslationRequest struct {
+	ID     uint   `param:"id" validate:"required"`
+	Locale string `param:"locale" validate:"required"`
+	Name   string `json:"name" validate:"required,max=200"`
+}
+
+type DeleteTranslationRequest struct {
+	ID     uint   `param:"id" validate:"required"`
+	Locale string `param:"locale" validate:"required"`
+}
+
+// translationLocale normalizes the locale of a translation. Names in the
+// default locale are not translations, they are set on the product or
+// category itself.
+func translationLocale(locale string, defaultLocale string) (string, error) {
+	locale = strings.ToLower(locale)
+
+	if !i18n.Supported(locale) {
+		return "", problem.Newf(http.StatusBadRequest, "Unsupported locale %s", locale)
+	}
+	if locale == defaultLocale {
+		return "", problem.New(http.StatusBadRequest, "Set the name itself for the default locale")
+	}
+
+	return locale, nil
+}
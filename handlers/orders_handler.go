@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"store_backend/auth"
+	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/problem"
+	"store_backend/repositories"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type OrdersHandler struct {
+	repos        repositories.Repositories
+	authenticate echo.MiddlewareFunc
+}
+
+func (h *OrdersHandler) RegisterRoutes(g *echo.Group) error {
+	orders := g.Group("/orders", h.authenticate, auth.Require(auth.PermOrdersRead), auth.RequireUser)
+
+	orders.GET("", h.GetOrders)
+	orders.GET("/:id", h.GetOrder)
+
+	return nil
+}
+
+// Operations documents the routes registered by RegisterRoutes.
+func (h *OrdersHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/orders", Response: []models.Order{}},
+		{Method: http.MethodGet, Path: "/orders/:id", Request: GetOrderRequest{}, Response: models.Order{}},
+	}
+}
+
+func (h *OrdersHandler) GetOrders(c echo.Context) error {
+	orders, err := h.repos.Orders.GetAllByUser(auth.CurrentUser(c).ID)
+	if err != nil {
+		return problem.Internal(err, "Failed to get orders")
+	}
+
+	return c.JSON(http.StatusOK, orders)
+}
+
+type GetOrderRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *OrdersHandler) GetOrder(c echo.Context) error {
+	data := GetOrderRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	order, err := h.repos.Orders.GetByID(data.ID)
+	if err == nil && !ownedByCaller(c, order.UserID) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, OrderNotFound)
+		}
+		return problem.Internal(err, "Failed to get order")
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+const (
+	OrderNotFound = "Order not found"
+)
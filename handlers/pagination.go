@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PaginatedResponse[T any] struct {
+	Data       []T     `json:"data"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"pageSize"`
+	TotalCount int64   `json:"totalCount"`
+	TotalPages int     `json:"totalPages"`
+	Next       *string `json:"next"`
+	Prev       *string `json:"prev"`
+}
+
+func newPaginatedResponse[T any](c echo.Context, data []T, page, pageSize int, total int64) PaginatedResponse[T] {
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	if data == nil {
+		data = []T{}
+	}
+
+	res := PaginatedResponse[T]{
+		Data:       data,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalCount: total,
+		TotalPages: totalPages,
+	}
+
+	if page < totalPages {
+		res.Next = pageLink(c, page+1)
+	}
+	if page > 1 && totalPages > 0 {
+		res.Prev = pageLink(c, min(page-1, totalPages))
+	}
+
+	return res
+}
+
+// pageLink rebuilds the current request URL with only the page parameter
+// changed, so filters and sorting carry over to the linked page.
+func pageLink(c echo.Context, page int) *string {
+	u := *c.Request().URL
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+
+	link := u.RequestURI()
+	return &link
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/payments"
+	"store_backend/problem"
+	"store_backend/repositories"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxWebhookSize limits the body of provider callbacks.
+const maxWebhookSize = 64 << 10
+
+type PaymentsHandler struct {
+	repos repositories.Repositories
+}
+
+func (h *PaymentsHandler) RegisterRoutes(g *echo.Group) error {
+	// Webhooks are called by the payment provider and authenticated by their
+	// signature instead of a user.
+	g.POST("/payments/webhooks/:provider", h.HandleWebhook)
+
+	return nil
+}
+
+// Operations documents the routes registered by RegisterRoutes.
+func (h *PaymentsHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodPost, Path: "/payments/webhooks/:provider", Response: models.Payment{},
+			Description: "Called by the payment provider with an event signed by it. The body is the event in the format of the provider."},
+	}
+}
+
+// HandleWebhook applies a payment event reported by the provider. Events that
+// were applied before are acknowledged again, so the provider stops retrying.
+func (h *PaymentsHandler) HandleWebhook(c echo.Context) error {
+	provider := h.repos.Payments.Provider()
+	if c.Param("provider") != provider.Name() {
+		return problem.New(http.StatusNotFound, "Unknown payment provider")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookSize))
+	if err != nil {
+		return problem.New(http.StatusBadRequest, "Invalid request format")
+	}
+
+	event, err := provider.ParseWebhook(c.Request().Header, body)
+	if err != nil {
+		return h.handlePaymentError(c, err)
+	}
+
+	payment, err := h.repos.Payments.HandleEvent(event)
+	if err != nil {
+		return h.handlePaymentError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, payment)
+}
+
+func (h *PaymentsHandler) handlePaymentError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, payments.ErrInvalidSignature):
+		return problem.New(http.StatusUnauthorized, "Invalid signature")
+	case errors.Is(err, payments.ErrInvalidEvent):
+		return problem.New(http.StatusBadRequest, "Invalid event")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.New(http.StatusNotFound, PaymentNotFound)
+	}
+	return problem.Internal(err, "Failed to handle payment event")
+}
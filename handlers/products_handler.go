@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"errors"
-	"log"
 	"net/http"
+	"store_backend/auth"
 	"store_backend/models"
+	"store_backend/openapi"
+	"store_backend/problem"
 	"store_backend/repositories"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/shopspring/decimal"
@@ -13,30 +16,107 @@ import (
 )
 
 type ProductsHandler struct {
-	repos repositories.Repositories
+	repos         repositories.Repositories
+	authenticate  echo.MiddlewareFunc
+	currency      echo.MiddlewareFunc
+	baseCurrency  string
+	defaultLocale string
 }
 
-func (h *ProductsHandler) RegisterRoutes(e *echo.Echo) error {
-	products := e.Group("/products")
+func (h *ProductsHandler) RegisterRoutes(g *echo.Group) error {
+	products := g.Group("/products", h.authenticate)
 
-	products.GET("", h.GetProducts)
-	products.GET("/:id", h.GetProduct)
-	products.POST("", h.CreateProduct)
-	products.PUT("/:id", h.UpdateProduct)
-	products.DELETE("/:id", h.DeleteProduct)
+	read := auth.Require(auth.PermCatalogRead)
+	write := auth.Require(auth.PermCatalogWrite)
+	inventory := auth.Require(auth.PermInventory)
+
+	products.GET("", h.GetProducts, read, h.currency)
+	products.GET("/:id", h.GetProduct, read, h.currency)
+	products.POST("", h.CreateProduct, write)
+	products.PUT("/:id", h.UpdateProduct, write)
+	products.DELETE("/:id", h.DeleteProduct, write)
+
+	products.GET("/:id/stock", h.GetStockMovements, inventory)
+	products.POST("/:id/stock", h.AdjustStock, inventory)
+
+	products.PUT("/:id/prices/:currency", h.SetPrice, write)
+	products.DELETE("/:id/prices/:currency", h.DeletePrice, write)
+
+	products.PUT("/:id/translations/:locale", h.SetTranslation, write)
+	products.DELETE("/:id/translations/:locale", h.DeleteTranslation, write)
 
 	return nil
 }
 
+// Operations documents the routes registered by RegisterRoutes.
+func (h *ProductsHandler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/products", Request: GetProductsRequest{}, Response: PaginatedResponse[models.Product]{}},
+		{Method: http.MethodGet, Path: "/products/:id", Request: GetProductRequest{}, Response: models.Product{}},
+		{Method: http.MethodPost, Path: "/products", Request: CreateProductRequest{}, Response: models.Product{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/products/:id", Request: UpdateProductRequest{}, Response: models.Product{}},
+		{Method: http.MethodDelete, Path: "/products/:id", Request: DeleteProductRequest{}},
+		{Method: http.MethodGet, Path: "/products/:id/stock", Request: GetStockMovementsRequest{}, Response: []models.StockMovement{}},
+		{Method: http.MethodPost, Path: "/products/:id/stock", Request: AdjustStockRequest{}, Response: models.StockMovement{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/products/:id/prices/:currency", Request: SetPriceRequest{}, Response: models.Product{}},
+		{Method: http.MethodDelete, Path: "/products/:id/prices/:currency", Request: DeletePriceRequest{}, Response: models.Product{}},
+		{Method: http.MethodPut, Path: "/products/:id/translations/:locale", Request: SetTranslationRequest{}, Response: models.Product{}},
+		{Method: http.MethodDelete, Path: "/products/:id/translations/:locale", Request: DeleteTranslationRequest{}, Response: models.Product{}},
+	}
+}
+
+type GetProductsRequest struct {
+	Page       int     `query:"page" validate:"omitempty,min=1"`
+	PageSize   int     `query:"pageSize" validate:"omitempty,min=1,max=100"`
+	CategoryID uint    `query:"categoryId"`
+	MinPrice   float64 `query:"minPrice" validate:"omitempty,min=0"`
+	MaxPrice   float64 `query:"maxPrice" validate:"omitempty,min=0,gtefield=MinPrice"`
+	InStock    bool    `query:"inStock"`
+	// Sort is one of the keys in repositories.ProductSortColumns, prefixed
+	// with "-" for descending order, e.g. "-price".
+	Sort string `query:"sort" validate:"omitempty,oneof=id -id name -name price -price createdAt -createdAt updatedAt -updatedAt"`
+}
+
 func (h *ProductsHandler) GetProducts(c echo.Context) error {
-	products, err := h.repos.Products.GetAll(nil)
+	data := GetProductsRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	opts := repositories.DefaultGetAllProductsOptions()
+	if data.Page > 0 {
+		opts.Page = data.Page
+	}
+	if data.PageSize > 0 {
+		opts.PageSize = data.PageSize
+	}
+	// Price filters are given in the request currency, products are stored
+	// with base prices.
+	rate := currencyOf(c).Rate.InexactFloat64()
+	opts.CategoryID = data.CategoryID
+	opts.MinPrice = data.MinPrice / rate
+	opts.MaxPrice = data.MaxPrice / rate
+	opts.InStock = data.InStock
+	if data.Sort != "" {
+		opts.SortBy, opts.SortDesc = parseSort(data.Sort)
+	}
 
+	products, total, err := h.repos.Products.GetAll(opts)
 	if err != nil {
-		log.Printf("error getting products: %v", err)
-		return c.NoContent(501)
+		return problem.Internal(err, "Failed to get products")
 	}
 
-	return c.JSON(http.StatusOK, products)
+	localizeProducts(c, products)
+
+	return c.JSON(http.StatusOK, newPaginatedResponse(c, products, opts.Page, opts.PageSize, total))
+}
+
+func parseSort(sort string) (string, bool) {
+	if strings.HasPrefix(sort, "-") {
+		return sort[1:], true
+	}
+	return sort, false
 }
 
 type GetProductRequest struct {
@@ -45,7 +125,7 @@ type GetProductRequest struct {
 
 func (h *ProductsHandler) GetProduct(c echo.Context) error {
 	req := GetProductRequest{}
-	if err := c.Bind(&req); err != nil {
+	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
@@ -53,36 +133,59 @@ func (h *ProductsHandler) GetProduct(c echo.Context) error {
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.NoContent(http.StatusNotFound)
-		} else {
-			log.Printf("error getting product: %v", err)
-			return c.NoContent(500)
+			return problem.New(http.StatusNotFound, "Product not found")
 		}
+		return problem.Internal(err, "Failed to get product")
 	}
 
+	localizeProduct(c, product)
+
 	return c.JSON(http.StatusOK, product)
 }
 
+// ProductSizeRequest is the weight of a product in kilograms and its
+// dimensions in centimetres.
+type ProductSizeRequest struct {
+	Weight decimal.Decimal `json:"weight" validate:"nonnegative"`
+	Length decimal.Decimal `json:"length" validate:"nonnegative"`
+	Width  decimal.Decimal `json:"width" validate:"nonnegative"`
+	Height decimal.Decimal `json:"height" validate:"nonnegative"`
+}
+
+func (r ProductSizeRequest) apply(product *models.Product) {
+	product.Weight = r.Weight
+	product.Length = r.Length
+	product.Width = r.Width
+	product.Height = r.Height
+}
+
 type CreateProductRequest struct {
-	Name       string          `json:"name" validate:"required"`
-	Price      decimal.Decimal `json:"price" validate:"required"`
-	CategoryID *uint           `json:"categoryId"`
+	Name          string          `json:"name" validate:"required,max=200"`
+	Price         decimal.Decimal `json:"price" validate:"required,positive"`
+	StockQuantity int             `json:"stockQuantity" validate:"min=0"`
+	CategoryID    *uint           `json:"categoryId"`
+	TaxClassID    *uint           `json:"taxClassId"`
+	ProductSizeRequest
 }
 
 func (h *ProductsHandler) CreateProduct(c echo.Context) error {
 	data := CreateProductRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
-	p := models.Product{Name: data.Name, Price: data.Price, CategoryID: data.CategoryID}
+	p := models.Product{
+		Name:          data.Name,
+		Price:         data.Price,
+		StockQuantity: data.StockQuantity,
+		CategoryID:    data.CategoryID,
+		TaxClassID:    data.TaxClassID,
+	}
+	data.apply(&p)
 	product, err := h.repos.Products.Create(&p)
 	if err != nil {
-		log.Printf("error creating product: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create product",
-		})
+		return problem.Internal(err, "Failed to create product")
 	}
 
 	return c.JSON(http.StatusCreated, product)
@@ -90,39 +193,38 @@ func (h *ProductsHandler) CreateProduct(c echo.Context) error {
 
 type UpdateProductRequest struct {
 	ID         uint            `param:"id" validate:"required"`
-	Name       string          `json:"name" validate:"required"`
-	Price      decimal.Decimal `json:"price" validate:"required"`
+	Name       string          `json:"name" validate:"required,max=200"`
+	Price      decimal.Decimal `json:"price" validate:"required,positive"`
 	CategoryID *uint           `json:"categoryId"`
+	TaxClassID *uint           `json:"taxClassId"`
+	ProductSizeRequest
 }
 
 func (h *ProductsHandler) UpdateProduct(c echo.Context) error {
 	data := UpdateProductRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
 	product, err := h.repos.Products.GetByID(data.ID)
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.NoContent(http.StatusNotFound)
-		} else {
-			log.Printf("error getting product: %v", err)
-			return c.NoContent(500)
+			return problem.New(http.StatusNotFound, "Product not found")
 		}
+		return problem.Internal(err, "Failed to get product")
 	}
 
 	product.Name = data.Name
 	product.Price = data.Price
 	product.CategoryID = data.CategoryID
+	product.TaxClassID = data.TaxClassID
+	data.apply(product)
 
 	product, err = h.repos.Products.Update(product)
 	if err != nil {
-		log.Printf("error updating product: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to update product",
-		})
+		return problem.Internal(err, "Failed to update product")
 	}
 
 	return c.JSON(http.StatusOK, product)
@@ -136,32 +238,192 @@ func (h *ProductsHandler) DeleteProduct(c echo.Context) error {
 	data := DeleteProductRequest{}
 
 	if err := bindAndValidate(c, &data); err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return err
 	}
 
 	// Check if product exists
 	_, err := h.repos.Products.GetByID(data.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "Product not found",
-			})
+			return problem.New(http.StatusNotFound, "Product not found")
 		}
 
-		log.Printf("error getting product for deletion: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to delete product",
-		})
+		return problem.Internal(err, "Failed to delete product")
 	}
 
 	err = h.repos.Products.Delete(data.ID)
 
 	if err != nil {
-		log.Printf("error deleting product: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to delete product",
-		})
+		return problem.Internal(err, "Failed to delete product")
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+type AdjustStockRequest struct {
+	ID     uint               `param:"id" validate:"required"`
+	Delta  int                `json:"delta" validate:"required"`
+	Reason models.StockReason `json:"reason" validate:"required,oneof=restock correction damaged returned"`
+	Note   string             `json:"note"`
+}
+
+func (h *ProductsHandler) AdjustStock(c echo.Context) error {
+	data := AdjustStockRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	movement, err := h.repos.Products.AdjustStock(data.ID, data.Delta, data.Reason, data.Note)
+	if err != nil {
+		var stockErr *repositories.InsufficientStockError
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, "Product not found")
+		}
+		if errors.As(err, &stockErr) {
+			return problem.Newf(http.StatusConflict, "Only %d in stock", stockErr.Available)
+		}
+
+		return problem.Internal(err, "Failed to adjust stock")
+	}
+
+	return c.JSON(http.StatusCreated, movement)
+}
+
+type GetStockMovementsRequest struct {
+	ID uint `param:"id" validate:"required"`
+}
+
+func (h *ProductsHandler) GetStockMovements(c echo.Context) error {
+	data := GetStockMovementsRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	movements, err := h.repos.Products.GetStockMovements(data.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, "Product not found")
+		}
+
+		return problem.Internal(err, "Failed to get stock movements")
+	}
+
+	return c.JSON(http.StatusOK, movements)
+}
+
+type SetPriceRequest struct {
+	ID       uint            `param:"id" validate:"required"`
+	Currency string          `param:"currency" validate:"required,len=3,alpha"`
+	Price    decimal.Decimal `json:"price" validate:"required,positive"`
+}
+
+// SetPrice gives the product a fixed price in a currency instead of
+// converting its base price.
+func (h *ProductsHandler) SetPrice(c echo.Context) error {
+	data := SetPriceRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	currency := strings.ToUpper(data.Currency)
+
+	if currency == h.baseCurrency {
+		return problem.New(http.StatusBadRequest, "Set the price itself for the base currency")
+	}
+
+	if _, err := h.repos.ExchangeRates.Get(currency); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.Newf(http.StatusBadRequest, "Unsupported currency %s", currency)
+		}
+		return problem.Internal(err, "Failed to set price")
+	}
+
+	if err := h.repos.Products.SetPrice(data.ID, currency, data.Price); err != nil {
+		return h.handlePriceError(c, err, "Failed to set price")
+	}
+
+	return h.returnProduct(c, data.ID)
+}
+
+type DeletePriceRequest struct {
+	ID       uint   `param:"id" validate:"required"`
+	Currency string `param:"currency" validate:"required"`
+}
+
+func (h *ProductsHandler) DeletePrice(c echo.Context) error {
+	data := DeletePriceRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	err := h.repos.Products.DeletePrice(data.ID, strings.ToUpper(data.Currency))
+	if err != nil {
+		return h.handlePriceError(c, err, "Failed to delete price")
+	}
+
+	return h.returnProduct(c, data.ID)
+}
+
+func (h *ProductsHandler) handlePriceError(c echo.Context, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.New(http.StatusNotFound, "Product or price not found")
+	}
+
+	return problem.Internal(err, message)
+}
+
+// SetTranslation sets the name of the product in a locale other than the
+// default locale.
+func (h *ProductsHandler) SetTranslation(c echo.Context) error {
+	data := SetTranslationRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	locale, err := translationLocale(data.Locale, h.defaultLocale)
+	if err != nil {
+		return err
+	}
+
+	if err := h.repos.Products.SetTranslation(data.ID, locale, data.Name); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, "Product not found")
+		}
+		return problem.Internal(err, "Failed to set translation")
+	}
+
+	return h.returnProduct(c, data.ID)
+}
+
+func (h *ProductsHandler) DeleteTranslation(c echo.Context) error {
+	data := DeleteTranslationRequest{}
+
+	if err := bindAndValidate(c, &data); err != nil {
+		return err
+	}
+
+	err := h.repos.Products.DeleteTranslation(data.ID, strings.ToLower(data.Locale))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.New(http.StatusNotFound, TranslationNotFound)
+		}
+		return problem.Internal(err, "Failed to delete translation")
+	}
+
+	return h.returnProduct(c, data.ID)
+}
+
+func (h *ProductsHandler) returnProduct(c echo.Context, id uint) error {
+	product, err := h.repos.Products.GetByID(id)
+	if err != nil {
+		return problem.Internal(err, "Failed to get product")
+	}
+
+	return c.JSON(http.StatusOK, product)
+}
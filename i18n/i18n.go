@@ -0,0 +1,87 @@
+// Package i18n picks the language of a request and translates API messages.
+//
+// Messages are written in English in the code and looked up by their English
+// text in the catalog of the request's locale, falling back to English.
+// Messages with arguments are translated by their format string.
+package i18n
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+)
+
+// Source is the locale messages are written in.
+const Source = "en"
+
+// Locales lists the locales with a message catalog.
+var Locales = []string{"en", "pl"}
+
+var catalogs = map[string]map[string]string{
+	"pl": polish,
+}
+
+const localeContextKey = "locale"
+
+// Supported reports whether there is a catalog for locale.
+func Supported(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
+
+// T translates message into locale. Messages missing from the catalog are
+// returned as they are.
+func T(locale string, message string) string {
+	if translated, ok := catalogs[locale][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf formats the translation of format into locale.
+func Sprintf(locale string, format string, args ...any) string {
+	return fmt.Sprintf(T(locale, format), args...)
+}
+
+// Negotiate picks the locale of each request from the Accept-Language header,
+// falling back to defaultLocale, and announces it in the Content-Language
+// header of the response.
+func Negotiate(defaultLocale string) echo.MiddlewareFunc {
+	if !Supported(defaultLocale) {
+		panic(fmt.Errorf("unsupported default locale: %s", defaultLocale))
+	}
+
+	// The first tag is what the matcher falls back to.
+	locales := append([]string{defaultLocale}, slices.DeleteFunc(slices.Clone(Locales), func(l string) bool {
+		return l == defaultLocale
+	})...)
+
+	tags := make([]language.Tag, len(locales))
+	for i, locale := range locales {
+		tags[i] = language.MustParse(locale)
+	}
+	matcher := language.NewMatcher(tags)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			accepted, _, _ := language.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))
+			_, index, _ := matcher.Match(accepted...)
+
+			locale := locales[index]
+			c.Set(localeContextKey, locale)
+			c.Response().Header().Set("Content-Language", locale)
+
+			return next(c)
+		}
+	}
+}
+
+// Locale returns the locale picked by Negotiate, or Source for requests that
+// did not go through it.
+func Locale(c echo.Context) string {
+	if locale, ok := c.Get(localeContextKey).(string); ok {
+		return locale
+	}
+	return Source
+}
@@ -0,0 +1,224 @@
+package i18n
+
+// polish is the Polish catalog, keyed by the English messages.
+var polish = map[string]string{
+	// HTTP status titles.
+	"Bad Request":              "Nieprawidłowe żądanie",
+	"Unauthorized":             "Brak autoryzacji",
+	"Payment Required":         "Wymagana płatność",
+	"Forbidden":                "Brak dostępu",
+	"Not Found":                "Nie znaleziono",
+	"Method Not Allowed":       "Niedozwolona metoda",
+	"Conflict":                 "Konflikt",
+	"Request Entity Too Large": "Zbyt duże żądanie",
+	"Unsupported Media Type":   "Nieobsługiwany typ danych",
+	"Unprocessable Entity":     "Nie można przetworzyć żądania",
+	"Too Many Requests":        "Zbyt wiele żądań",
+	"Internal Server Error":    "Wewnętrzny błąd serwera",
+	"Bad Gateway":              "Błąd bramy",
+	"Service Unavailable":      "Usługa niedostępna",
+	"Validation failed":        "Błąd walidacji",
+	"Constraint violation":     "Naruszenie ograniczenia",
+
+	// Generic problems.
+	"Invalid request format":                                    "Nieprawidłowy format żądania",
+	"API documentation is not available":                        "Dokumentacja API jest niedostępna",
+	"Resource not found":                                        "Nie znaleziono zasobu",
+	"Resource already exists":                                   "Zasób już istnieje",
+	"Resource is referenced by or refers to a missing resource": "Zasób jest używany przez inny zasób lub odwołuje się do nieistniejącego zasobu",
+
+	// Validation rules.
+	"{field} is invalid":                                                        "Pole {field} jest nieprawidłowe",
+	"{field} has the wrong type":                                                "Pole {field} ma nieprawidłowy typ",
+	"{field} is required":                                                       "Pole {field} jest wymagane",
+	"{field} is required with {param}":                                          "Pole {field} jest wymagane, jeśli podano pole {param}",
+	"{field} is required without {param}":                                       "Pole {field} jest wymagane, jeśli brak pola {param}",
+	"{field} must be at least {param}":                                          "Pole {field} musi wynosić co najmniej {param}",
+	"{field} must be at least {param} characters long":                          "Pole {field} musi mieć co najmniej {param} znaków",
+	"{field} must have at least {param} items":                                  "Pole {field} musi mieć co najmniej {param} elementów",
+	"{field} must be at most {param}":                                           "Pole {field} może wynosić najwyżej {param}",
+	"{field} must be at most {param} characters long":                           "Pole {field} może mieć najwyżej {param} znaków",
+	"{field} must have at most {param} items":                                   "Pole {field} może mieć najwyżej {param} elementów",
+	"{field} must be {param}":                                                   "Pole {field} musi wynosić {param}",
+	"{field} must be exactly {param} characters long":                           "Pole {field} musi mieć dokładnie {param} znaków",
+	"{field} must have exactly {param} items":                                   "Pole {field} musi mieć dokładnie {param} elementów",
+	"{field} must be greater than or equal to {param}":                          "Pole {field} musi być większe lub równe polu {param}",
+	"{field} must be one of: {param}":                                           "Pole {field} musi mieć jedną z wartości: {param}",
+	"{field} must contain only letters":                                         "Pole {field} może zawierać tylko litery",
+	"{field} must be a valid email address":                                     "Pole {field} musi być poprawnym adresem e-mail",
+	"{field} must be a phone number in international format, e.g. +48123456789": "Pole {field} musi być numerem telefonu w formacie międzynarodowym, np. +48123456789",
+	"{field} must be a known permission":                                        "Pole {field} musi być znanym uprawnieniem",
+	"{field} must be positive":                                                  "Pole {field} musi być dodatnie",
+	"{field} cannot be negative":                                                "Pole {field} nie może być ujemne",
+
+	// Authentication and authorization.
+	"Missing or invalid credentials":   "Brak lub nieprawidłowe dane uwierzytelniające",
+	"Failed to authenticate request":   "Nie udało się uwierzytelnić żądania",
+	"API key is missing scope %s":      "Klucz API nie ma zakresu %s",
+	"Role %s is missing permission %s": "Rola %s nie ma uprawnienia %s",
+	"Invalid email or password":        "Nieprawidłowy e-mail lub hasło",
+	"Email already registered":         "Ten e-mail jest już zarejestrowany",
+	"Invalid refresh token":            "Nieprawidłowy token odświeżania",
+	"Failed to register":               "Nie udało się zarejestrować",
+	"Failed to log in":                 "Nie udało się zalogować",
+	"Failed to log out":                "Nie udało się wylogować",
+	"Failed to refresh token":          "Nie udało się odświeżyć tokenu",
+
+	// Products and categories.
+	"Product not found":                          "Nie znaleziono produktu",
+	"Product or price not found":                 "Nie znaleziono produktu lub ceny",
+	"Category not found":                         "Nie znaleziono kategorii",
+	"Translation not found":                      "Nie znaleziono tłumaczenia",
+	"Unsupported locale %s":                      "Nieobsługiwany język %s",
+	"Set the name itself for the default locale": "W domyślnym języku ustaw samą nazwę",
+	"Set the price itself for the base currency": "W walucie bazowej ustaw samą cenę",
+	"Unsupported currency %s":                    "Nieobsługiwana waluta %s",
+	"Invalid currency code %q":                   "Nieprawidłowy kod waluty %q",
+	"The base currency %s has no exchange rate":  "Waluta bazowa %s nie ma kursu wymiany",
+	"Exchange rate of %s must be positive":       "Kurs wymiany %s musi być dodatni",
+	"Only %d in stock":                           "Na stanie jest tylko %d szt.",
+	"Failed to get products":                     "Nie udało się pobrać produktów",
+	"Failed to get product":                      "Nie udało się pobrać produktu",
+	"Failed to create product":                   "Nie udało się utworzyć produktu",
+	"Failed to update product":                   "Nie udało się zaktualizować produktu",
+	"Failed to delete product":                   "Nie udało się usunąć produktu",
+	"Failed to get stock movements":              "Nie udało się pobrać ruchów magazynowych",
+	"Failed to adjust stock":                     "Nie udało się skorygować stanu magazynowego",
+	"Failed to set price":                        "Nie udało się ustawić ceny",
+	"Failed to delete price":                     "Nie udało się usunąć ceny",
+	"Failed to set translation":                  "Nie udało się ustawić tłumaczenia",
+	"Failed to delete translation":               "Nie udało się usunąć tłumaczenia",
+	"Failed to get categories":                   "Nie udało się pobrać kategorii",
+	"Failed to get category":                     "Nie udało się pobrać kategorii",
+	"Failed to get category products":            "Nie udało się pobrać produktów kategorii",
+	"Failed to create category":                  "Nie udało się utworzyć kategorii",
+	"Failed to update category":                  "Nie udało się zaktualizować kategorii",
+	"Failed to delete category":                  "Nie udało się usunąć kategorii",
+
+	// Carts.
+	"Cart not found":                                           "Nie znaleziono koszyka",
+	"Cart can no longer be modified":                           "Koszyka nie można już zmieniać",
+	"Cart is empty":                                            "Koszyk jest pusty",
+	"Product not in cart":                                      "Produktu nie ma w koszyku",
+	"Only %d of product %d in stock":                           "Na stanie jest tylko %d szt. produktu %d",
+	"Cannot merge a cart into itself":                          "Nie można scalić koszyka z samym sobą",
+	"Cannot change cart status from %s to %s":                  "Nie można zmienić statusu koszyka z %s na %s",
+	"Checked out carts cannot be reopened":                     "Nie można ponownie otworzyć koszyka po złożeniu zamówienia",
+	"Use checkout to complete a cart":                          "Aby sfinalizować koszyk, złóż zamówienie",
+	"Choose a payment method":                                  "Wybierz metodę płatności",
+	"Choose a shipping method first":                           "Najpierw wybierz metodę dostawy",
+	"Shipping method is not available for this cart":           "Ta metoda dostawy jest niedostępna dla tego koszyka",
+	"Shipping address required":                                "Wymagany jest adres dostawy",
+	"Coupon %s is no longer available":                         "Kupon %s nie jest już dostępny",
+	"Coupon is not valid at this time":                         "Kupon nie jest teraz ważny",
+	"Coupon not applied to cart":                               "Kupon nie został użyty w koszyku",
+	"Failed to create cart":                                    "Nie udało się utworzyć koszyka",
+	"Failed to get cart":                                       "Nie udało się pobrać koszyka",
+	"Failed to get carts":                                      "Nie udało się pobrać koszyków",
+	"Failed to get cart products":                              "Nie udało się pobrać produktów koszyka",
+	"Failed to get cart history":                               "Nie udało się pobrać historii koszyka",
+	"Failed to get cart payments":                              "Nie udało się pobrać płatności koszyka",
+	"Failed to delete cart":                                    "Nie udało się usunąć koszyka",
+	"Failed to clear cart":                                     "Nie udało się wyczyścić koszyka",
+	"Failed to add product to cart":                            "Nie udało się dodać produktu do koszyka",
+	"Failed to update product quantity":                        "Nie udało się zmienić ilości produktu",
+	"Failed to remove product from cart":                       "Nie udało się usunąć produktu z koszyka",
+	"Failed to merge carts":                                    "Nie udało się scalić koszyków",
+	"Failed to apply coupon":                                   "Nie udało się użyć kuponu",
+	"Failed to remove coupon":                                  "Nie udało się usunąć kuponu",
+	"Failed to set shipping method":                            "Nie udało się ustawić metody dostawy",
+	"Failed to set cart destination":                           "Nie udało się ustawić miejsca dostawy koszyka",
+	"Failed to get shipping options":                           "Nie udało się pobrać opcji dostawy",
+	"Failed to change cart status":                             "Nie udało się zmienić statusu koszyka",
+	"Failed to check out cart":                                 "Nie udało się złożyć zamówienia",
+	"Product added, but failed to retrieve updated cart":       "Produkt dodano, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Quantity updated, but failed to retrieve updated cart":    "Ilość zmieniono, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Product removed, but failed to retrieve updated cart":     "Produkt usunięto, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Cart cleared, but failed to retrieve updated cart":        "Koszyk wyczyszczono, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Carts merged, but failed to retrieve updated cart":        "Koszyki scalono, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Coupon applied, but failed to retrieve updated cart":      "Kupon użyto, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Coupon removed, but failed to retrieve updated cart":      "Kupon usunięto, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Shipping method set, but failed to retrieve updated cart": "Metodę dostawy ustawiono, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Destination set, but failed to retrieve updated cart":     "Miejsce dostawy ustawiono, ale nie udało się pobrać zaktualizowanego koszyka",
+	"Status changed, but failed to retrieve updated cart":      "Status zmieniono, ale nie udało się pobrać zaktualizowanego koszyka",
+
+	// Orders and addresses.
+	"Order not found":           "Nie znaleziono zamówienia",
+	"Failed to get order":       "Nie udało się pobrać zamówienia",
+	"Failed to get orders":      "Nie udało się pobrać zamówień",
+	"Address not found":         "Nie znaleziono adresu",
+	"Invalid postcode for %s":   "Nieprawidłowy kod pocztowy dla kraju %s",
+	"Region is required for %s": "Region jest wymagany dla kraju %s",
+	"Failed to get address":     "Nie udało się pobrać adresu",
+	"Failed to get addresses":   "Nie udało się pobrać adresów",
+	"Failed to create address":  "Nie udało się utworzyć adresu",
+	"Failed to update address":  "Nie udało się zaktualizować adresu",
+	"Failed to delete address":  "Nie udało się usunąć adresu",
+
+	// Payments.
+	"Payment not found":                          "Nie znaleziono płatności",
+	"Payment declined: %s":                       "Płatność odrzucona: %s",
+	"Payment provider unavailable":               "Operator płatności jest niedostępny",
+	"Cannot %s a %s payment":                     "Nie można wykonać operacji %s na płatności o statusie %s",
+	"Refund amount cannot be negative":           "Kwota zwrotu nie może być ujemna",
+	"Refund exceeds what is left of the payment": "Kwota zwrotu przekracza pozostałą kwotę płatności",
+	"Unknown payment provider":                   "Nieznany operator płatności",
+	"Invalid signature":                          "Nieprawidłowy podpis",
+	"Invalid event":                              "Nieprawidłowe zdarzenie",
+	"Failed to handle payment event":             "Nie udało się obsłużyć zdarzenia płatności",
+	"Failed to get payments":                     "Nie udało się pobrać płatności",
+	"Failed to capture payment":                  "Nie udało się pobrać środków",
+	"Failed to refund payment":                   "Nie udało się zwrócić płatności",
+	"Failed to void payment":                     "Nie udało się anulować płatności",
+
+	// Administration.
+	"User not found":                                 "Nie znaleziono użytkownika",
+	"Admins cannot change their own role":            "Administrator nie może zmienić własnej roli",
+	"API key not found":                              "Nie znaleziono klucza API",
+	"Coupon not found":                               "Nie znaleziono kuponu",
+	"Coupon code already exists":                     "Kod kuponu już istnieje",
+	"Coupon must end after it starts":                "Kupon musi kończyć się po rozpoczęciu",
+	"Expiry must be in the future":                   "Data wygaśnięcia musi być w przyszłości",
+	"Cannot grant scopes you do not hold: %s":        "Nie można nadać zakresów, których nie posiadasz: %s",
+	"Promotion not found":                            "Nie znaleziono promocji",
+	"Tax class not found":                            "Nie znaleziono klasy podatkowej",
+	"Tax class already exists":                       "Klasa podatkowa już istnieje",
+	"Tax class already has a rate for this location": "Klasa podatkowa ma już stawkę dla tej lokalizacji",
+	"Tax rate not found":                             "Nie znaleziono stawki podatku",
+	"Shipping zone not found":                        "Nie znaleziono strefy dostawy",
+	"Shipping method not found":                      "Nie znaleziono metody dostawy",
+	"Exchange rate not found":                        "Nie znaleziono kursu wymiany",
+	"Failed to get users":                            "Nie udało się pobrać użytkowników",
+	"Failed to update user role":                     "Nie udało się zmienić roli użytkownika",
+	"Failed to get API keys":                         "Nie udało się pobrać kluczy API",
+	"Failed to create API key":                       "Nie udało się utworzyć klucza API",
+	"Failed to update API key scopes":                "Nie udało się zmienić zakresów klucza API",
+	"Failed to revoke API key":                       "Nie udało się unieważnić klucza API",
+	"Failed to get coupons":                          "Nie udało się pobrać kuponów",
+	"Failed to create coupon":                        "Nie udało się utworzyć kuponu",
+	"Failed to update coupon":                        "Nie udało się zaktualizować kuponu",
+	"Failed to delete coupon":                        "Nie udało się usunąć kuponu",
+	"Failed to get promotions":                       "Nie udało się pobrać promocji",
+	"Failed to create promotion":                     "Nie udało się utworzyć promocji",
+	"Failed to update promotion":                     "Nie udało się zaktualizować promocji",
+	"Failed to delete promotion":                     "Nie udało się usunąć promocji",
+	"Failed to get tax classes":                      "Nie udało się pobrać klas podatkowych",
+	"Failed to create tax class":                     "Nie udało się utworzyć klasy podatkowej",
+	"Failed to update tax class":                     "Nie udało się zaktualizować klasy podatkowej",
+	"Failed to delete tax class":                     "Nie udało się usunąć klasy podatkowej",
+	"Failed to get tax rates":                        "Nie udało się pobrać stawek podatku",
+	"Failed to create tax rate":                      "Nie udało się utworzyć stawki podatku",
+	"Failed to update tax rate":                      "Nie udało się zaktualizować stawki podatku",
+	"Failed to delete tax rate":                      "Nie udało się usunąć stawki podatku",
+	"Failed to get shipping zones":                   "Nie udało się pobrać stref dostawy",
+	"Failed to create shipping zone":                 "Nie udało się utworzyć strefy dostawy",
+	"Failed to update shipping zone":                 "Nie udało się zaktualizować strefy dostawy",
+	"Failed to delete shipping zone":                 "Nie udało się usunąć strefy dostawy",
+	"Failed to create shipping method":               "Nie udało się utworzyć metody dostawy",
+	"Failed to update shipping method":               "Nie udało się zaktualizować metody dostawy",
+	"Failed to delete shipping method":               "Nie udało się usunąć metody dostawy",
+	"Failed to get exchange rate":                    "Nie udało się pobrać kursu wymiany",
+	"Failed to get exchange rates":                   "Nie udało się pobrać kursów wymiany",
+	"Failed to set exchange rates":                   "Nie udało się ustawić kursów wymiany",
+	"Failed to delete exchange rate":                 "Nie udało się usunąć kursu wymiany",
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"store_backend/environment"
+	"store_backend/repositories"
+	"time"
+)
+
+// StartReservationSweeper releases expired cart reservations in the background
+// until ctx is cancelled.
+func StartReservationSweeper(ctx context.Context, carts *repositories.CartRepository, env environment.Environment) {
+	ticker := time.NewTicker(env.ReservationSweepInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				released, err := carts.ReleaseExpiredReservations(now)
+				if err != nil {
+					env.Logger.Error("releasing expired reservations", "error", err)
+				} else if released > 0 {
+					env.Logger.Info("released expired reservations", "items", released)
+				}
+			}
+		}
+	}()
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"store_backend/database"
 	"store_backend/environment"
 	"store_backend/handlers"
+	"store_backend/jobs"
 	"store_backend/repositories"
 	"store_backend/server"
 )
@@ -11,9 +13,11 @@ import (
 func main() {
 	env := environment.Initialize()
 	db := database.Initialize(env)
-	repos := repositories.Initialize(db)
-	handlers := handlers.Initialize(repos)
+	repos := repositories.Initialize(db, env)
+	versions := handlers.Initialize(repos, env)
 
-	server := server.Initialize(handlers, env)
+	jobs.StartReservationSweeper(context.Background(), repos.Carts, env)
+
+	server := server.Initialize(versions, repos, env)
 	server.Start()
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -14,21 +16,617 @@ type Model struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+type Role string
+
+const (
+	RoleAdmin          Role = "admin"
+	RoleCatalogManager Role = "catalog_manager"
+	RoleCustomer       Role = "customer"
+	// RoleGuest is the role of anonymous requests and is never stored.
+	RoleGuest Role = "guest"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleCatalogManager, RoleCustomer:
+		return true
+	}
+	return false
+}
+
+type User struct {
+	Model
+	Email        string `json:"email" gorm:"not null;uniqueIndex"`
+	Name         string `json:"name"`
+	Role         Role   `json:"role" gorm:"not null;default:customer"`
+	PasswordHash string `json:"-" gorm:"not null"`
+}
+
+// PostalAddress is where an order is shipped or billed to.
+type PostalAddress struct {
+	Name     string `json:"name"`
+	Company  string `json:"company"`
+	Line1    string `json:"line1"`
+	Line2    string `json:"line2"`
+	City     string `json:"city"`
+	Region   string `json:"region"`
+	Postcode string `json:"postcode"`
+	Country  string `json:"country" gorm:"size:2"`
+	Phone    string `json:"phone"`
+}
+
+// Address is an entry of a user's address book. A user has at most one
+// default shipping and one default billing address.
+type Address struct {
+	Model
+	UserID uint `json:"userId" gorm:"not null;index"`
+	PostalAddress
+	DefaultShipping bool `json:"defaultShipping" gorm:"not null;default:false"`
+	DefaultBilling  bool `json:"defaultBilling" gorm:"not null;default:false"`
+}
+
+// Session is a single login of a user, identified by its refresh token. Only a
+// hash of the refresh token is stored.
+type Session struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"-"`
+	UserID    uint      `json:"-" gorm:"not null;index"`
+	User      *User     `json:"-"`
+	TokenHash string    `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// APIKey authenticates server-to-server callers. Scopes lists the permissions
+// the key grants. Only a hash of the key is stored; Prefix is kept in clear so
+// keys can be told apart.
+type APIKey struct {
+	Model
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	KeyHash     string     `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes      []string   `json:"scopes" gorm:"serializer:json"`
+	CreatedByID *uint      `json:"createdById"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Product tracks two stock levels: StockQuantity is what is physically on
+// hand, AvailableQuantity is what is left after cart reservations.
 type Product struct {
 	Model
-	Name       string          `json:"name"`
-	Price      decimal.Decimal `json:"price" gorm:"type:decimal(10,2);"`
-	CategoryID *uint           `json:"categoryId"`
-	Category   *Category       `json:"-"`
+	Name              string          `json:"name"`
+	Price             decimal.Decimal `json:"price" gorm:"type:decimal(10,2);"`
+	StockQuantity     int             `json:"stockQuantity" gorm:"not null;default:0"`
+	AvailableQuantity int             `json:"availableQuantity" gorm:"not null;default:0"`
+	CategoryID        *uint           `json:"categoryId"`
+	Category          *Category       `json:"-"`
+	// TaxClassID overrides the tax class of the category.
+	TaxClassID *uint `json:"taxClassId"`
+	// Price is in the base currency. Prices lists fixed prices in other
+	// currencies, which are used instead of converting Price.
+	Prices []ProductPrice `json:"prices"`
+	// Currency is the currency Price is shown in, it is set per response.
+	Currency string `json:"currency,omitempty" gorm:"-"`
+	// Name is in the default locale. Translations lists the name in other
+	// locales.
+	Translations []ProductTranslation `json:"translations"`
+	// Weight is in kilograms and the dimensions in centimetres. They decide
+	// what shipping costs and which methods can carry the product.
+	Weight decimal.Decimal `json:"weight" gorm:"type:decimal(10,3);"`
+	Length decimal.Decimal `json:"length" gorm:"type:decimal(10,2);"`
+	Width  decimal.Decimal `json:"width" gorm:"type:decimal(10,2);"`
+	Height decimal.Decimal `json:"height" gorm:"type:decimal(10,2);"`
+}
+
+// ProductPrice is the price of a product in a currency other than the base
+// currency.
+type ProductPrice struct {
+	ID        uint            `json:"-" gorm:"primarykey"`
+	ProductID uint            `json:"-" gorm:"not null;uniqueIndex:idx_product_prices_currency"`
+	Currency  string          `json:"currency" gorm:"not null;size:3;uniqueIndex:idx_product_prices_currency"`
+	Price     decimal.Decimal `json:"price" gorm:"type:decimal(10,2);"`
+}
+
+// ProductTranslation is the name of a product in a locale other than the
+// default locale.
+type ProductTranslation struct {
+	ID        uint   `json:"-" gorm:"primarykey"`
+	ProductID uint   `json:"-" gorm:"not null;uniqueIndex:idx_product_translations_locale"`
+	Locale    string `json:"locale" gorm:"not null;size:8;uniqueIndex:idx_product_translations_locale"`
+	Name      string `json:"name" gorm:"not null"`
+}
+
+// ExchangeRate is how many units of Currency one unit of the base currency
+// is worth.
+type ExchangeRate struct {
+	Currency  string          `json:"currency" gorm:"primarykey;size:3"`
+	Rate      decimal.Decimal `json:"rate" gorm:"type:decimal(18,8);"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// NameIn returns the name of the product in locale, or the default name if
+// it is not translated.
+func (p *Product) NameIn(locale string) string {
+	for _, t := range p.Translations {
+		if t.Locale == locale {
+			return t.Name
+		}
+	}
+	return p.Name
+}
+
+// LongestSide returns the largest of the product's dimensions.
+func (p *Product) LongestSide() decimal.Decimal {
+	return decimal.Max(p.Length, p.Width, p.Height)
+}
+
+// EffectiveTaxClassID returns the tax class the product is taxed with: its own, or
+// else the one of its category. Nil means the product is not taxed.
+func (p *Product) EffectiveTaxClassID() *uint {
+	if p.TaxClassID != nil {
+		return p.TaxClassID
+	}
+	if p.Category != nil {
+		return p.Category.TaxClassID
+	}
+	return nil
+}
+
+type StockReason string
+
+const (
+	StockRestock    StockReason = "restock"
+	StockCorrection StockReason = "correction"
+	StockDamaged    StockReason = "damaged"
+	StockReturned   StockReason = "returned"
+	StockSale       StockReason = "sale"
+	StockCancelled  StockReason = "cancelled"
+)
+
+// StockMovement records a single change of a product's stock. Quantity is the
+// stock level after the change was applied.
+type StockMovement struct {
+	ID        uint        `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time   `json:"createdAt"`
+	ProductID uint        `json:"productId" gorm:"not null;index"`
+	Delta     int         `json:"delta"`
+	Quantity  int         `json:"quantity"`
+	Reason    StockReason `json:"reason"`
+	Note      string      `json:"note"`
 }
 
 type Category struct {
 	Model
-	Name     string    `json:"name"`
-	Products []Product `json:"-"`
+	Name       string    `json:"name"`
+	TaxClassID *uint     `json:"taxClassId"`
+	Products   []Product `json:"-"`
+	// Name is in the default locale. Translations lists the name in other
+	// locales.
+	Translations []CategoryTranslation `json:"translations"`
+}
+
+// CategoryTranslation is the name of a category in a locale other than the
+// default locale.
+type CategoryTranslation struct {
+	ID         uint   `json:"-" gorm:"primarykey"`
+	CategoryID uint   `json:"-" gorm:"not null;uniqueIndex:idx_category_translations_locale"`
+	Locale     string `json:"locale" gorm:"not null;size:8;uniqueIndex:idx_category_translations_locale"`
+	Name       string `json:"name" gorm:"not null"`
+}
+
+// NameIn returns the name of the category in locale, or the default name if
+// it is not translated.
+func (c *Category) NameIn(locale string) string {
+	for _, t := range c.Translations {
+		if t.Locale == locale {
+			return t.Name
+		}
+	}
+	return c.Name
+}
+
+// TaxClass groups products that are taxed the same way, for example
+// "standard" or "reduced".
+type TaxClass struct {
+	Model
+	Name string `json:"name" gorm:"not null;uniqueIndex"`
 }
 
+// TaxRate is the rate of a tax class in a country. A rate with a Region
+// applies only there and takes precedence over the rate for the whole
+// country. Rate is a percentage.
+type TaxRate struct {
+	Model
+	TaxClassID uint            `json:"taxClassId" gorm:"not null;uniqueIndex:idx_tax_rates_location"`
+	Country    string          `json:"country" gorm:"not null;size:2;uniqueIndex:idx_tax_rates_location"`
+	Region     string          `json:"region" gorm:"not null;default:'';uniqueIndex:idx_tax_rates_location"`
+	Name       string          `json:"name"`
+	Rate       decimal.Decimal `json:"rate" gorm:"type:decimal(5,2);"`
+}
+
+type CartStatus string
+
+const (
+	CartActive     CartStatus = "active"
+	CartLocked     CartStatus = "locked"
+	CartCheckedOut CartStatus = "checked_out"
+	CartAbandoned  CartStatus = "abandoned"
+	CartExpired    CartStatus = "expired"
+	// CartMerged carts had their contents moved into another cart.
+	CartMerged CartStatus = "merged"
+)
+
+// cartTransitions lists the statuses each status may move to. Statuses
+// without an entry are final. Checked out carts are reopened when the payment
+// for their order does not go through.
+var cartTransitions = map[CartStatus][]CartStatus{
+	CartActive:     {CartLocked, CartAbandoned, CartExpired, CartMerged},
+	CartLocked:     {CartActive, CartCheckedOut, CartAbandoned, CartExpired, CartMerged},
+	CartCheckedOut: {CartActive},
+	CartAbandoned:  {CartActive},
+}
+
+func (s CartStatus) CanTransitionTo(to CartStatus) bool {
+	for _, allowed := range cartTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Cart belongs to a user when UserID is set. Carts without a user are guest
+// carts and can only be used with the token handed out when they were
+// created, TokenHash being its hash.
 type Cart struct {
 	Model
-	Products []Product `json:"products" gorm:"many2many:cart_products;"`
+	UserID    *uint      `json:"userId" gorm:"index"`
+	TokenHash string     `json:"-"`
+	Status    CartStatus `json:"status" gorm:"not null;default:active;index"`
+	Items     []CartItem `json:"items"`
+	// Coupons are the codes applied to the cart. Whether they still give a
+	// discount is decided when the cart is priced.
+	Coupons []Coupon `json:"coupons" gorm:"many2many:cart_coupons"`
+	// Country and Region are where the order will be delivered, which decides
+	// the tax rates. An empty Country means the store's default country.
+	// Country and Postcode decide the shipping methods on offer.
+	Country  string `json:"country" gorm:"size:2"`
+	Region   string `json:"region"`
+	Postcode string `json:"postcode"`
+	// ShippingMethodID is the shipping method chosen by the customer.
+	ShippingMethodID *uint `json:"shippingMethodId"`
+}
+
+// CartStatusChange records a single cart status transition. From is empty for
+// the entry written when the cart is created.
+type CartStatusChange struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time  `json:"createdAt"`
+	CartID    uint       `json:"cartId" gorm:"not null;index"`
+	From      CartStatus `json:"from" gorm:"column:from_status"`
+	To        CartStatus `json:"to" gorm:"column:to_status"`
+	Reason    string     `json:"reason"`
+}
+
+// CartItem is a single line of a cart. UnitPrice is captured when the product
+// is first added, so later price changes do not affect items already in a cart.
+// ReservedQuantity units are held out of the product's available stock until
+// ReservedUntil.
+type CartItem struct {
+	ID               uint            `json:"id" gorm:"primarykey"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+	CartID           uint            `json:"cartId" gorm:"not null;uniqueIndex:idx_cart_items_cart_product"`
+	ProductID        uint            `json:"productId" gorm:"not null;uniqueIndex:idx_cart_items_cart_product"`
+	Product          *Product        `json:"product,omitempty"`
+	Quantity         int             `json:"quantity" gorm:"not null;default:1"`
+	UnitPrice        decimal.Decimal `json:"unitPrice" gorm:"type:decimal(10,2);"`
+	ReservedQuantity int             `json:"reservedQuantity" gorm:"not null;default:0"`
+	ReservedUntil    *time.Time      `json:"reservedUntil" gorm:"index"`
+}
+
+// ShippingZone is a set of destinations served by the same shipping methods.
+// Countries lists country codes, a zone without countries matches every
+// country. Postcodes narrows the zone down to postcodes starting with one of
+// the prefixes.
+type ShippingZone struct {
+	Model
+	Name      string           `json:"name" gorm:"not null"`
+	Countries []string         `json:"countries" gorm:"serializer:json"`
+	Postcodes []string         `json:"postcodes" gorm:"serializer:json"`
+	Methods   []ShippingMethod `json:"methods,omitempty" gorm:"foreignKey:ZoneID"`
+}
+
+// Matches reports whether the zone covers the destination.
+func (z ShippingZone) Matches(country string, postcode string) bool {
+	if len(z.Countries) > 0 && !slices.Contains(z.Countries, country) {
+		return false
+	}
+	if len(z.Postcodes) == 0 {
+		return true
+	}
+
+	postcode = NormalizePostcode(postcode)
+	for _, prefix := range z.Postcodes {
+		if strings.HasPrefix(postcode, NormalizePostcode(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Specificity ranks zones matching the same destination: zones listing
+// postcodes come first, then zones listing countries, then catch-all zones.
+func (z ShippingZone) Specificity() int {
+	switch {
+	case len(z.Postcodes) > 0:
+		return 2
+	case len(z.Countries) > 0:
+		return 1
+	}
+	return 0
+}
+
+// NormalizePostcode drops spaces and dashes so that postcodes compare equal
+// however they were typed.
+func NormalizePostcode(postcode string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(postcode))
+}
+
+type ShippingRateType string
+
+const (
+	ShippingFlat        ShippingRateType = "flat"
+	ShippingWeightBased ShippingRateType = "weight_based"
+	ShippingPriceTiered ShippingRateType = "price_tiered"
+)
+
+// ShippingTier charges Price once the cart reaches Threshold: its weight in
+// kilograms for weight based methods, its value after discounts for price
+// tiered ones.
+type ShippingTier struct {
+	Threshold decimal.Decimal `json:"threshold"`
+	Price     decimal.Decimal `json:"price"`
+}
+
+// ShippingMethod is a way of delivering orders to a zone. Flat methods cost
+// Price, the others the price of the highest tier the cart reaches. MaxWeight
+// and MaxLength, the longest side of any product, limit what the method can
+// carry; zero means no limit.
+type ShippingMethod struct {
+	Model
+	ZoneID    uint             `json:"zoneId" gorm:"not null;index"`
+	Name      string           `json:"name" gorm:"not null"`
+	Type      ShippingRateType `json:"type" gorm:"not null"`
+	Price     decimal.Decimal  `json:"price" gorm:"type:decimal(10,2);"`
+	Tiers     []ShippingTier   `json:"tiers" gorm:"serializer:json"`
+	MaxWeight decimal.Decimal  `json:"maxWeight" gorm:"type:decimal(10,3);"`
+	MaxLength decimal.Decimal  `json:"maxLength" gorm:"type:decimal(10,2);"`
+	Active    bool             `json:"active" gorm:"not null;index"`
+}
+
+type CouponType string
+
+const (
+	CouponPercentage   CouponType = "percentage"
+	CouponFixedAmount  CouponType = "fixed_amount"
+	CouponFreeShipping CouponType = "free_shipping"
+)
+
+// Coupon is a discount code customers can apply to a cart. Value is a
+// percentage for percentage coupons and an amount for fixed amount coupons.
+// A coupon with CategoryIDs only discounts products in those categories, the
+// minimum cart value is checked against the whole cart.
+type Coupon struct {
+	Model
+	Code         string          `json:"code" gorm:"not null;uniqueIndex"`
+	Type         CouponType      `json:"type" gorm:"not null"`
+	Value        decimal.Decimal `json:"value" gorm:"type:decimal(10,2);"`
+	MinCartValue decimal.Decimal `json:"minCartValue" gorm:"type:decimal(10,2);"`
+	CategoryIDs  []uint          `json:"categoryIds" gorm:"serializer:json"`
+	// UsageLimit is the number of orders the coupon can be used for, nil
+	// means unlimited. UsageCount goes up at checkout.
+	UsageLimit *int       `json:"usageLimit"`
+	UsageCount int        `json:"usageCount" gorm:"not null;default:0"`
+	ValidFrom  *time.Time `json:"validFrom"`
+	ValidUntil *time.Time `json:"validUntil"`
+}
+
+// Usable reports whether the coupon is within its validity window at now and
+// has uses left.
+func (c Coupon) Usable(now time.Time) bool {
+	if c.ValidFrom != nil && now.Before(*c.ValidFrom) {
+		return false
+	}
+	if c.ValidUntil != nil && !now.Before(*c.ValidUntil) {
+		return false
+	}
+	return c.UsageLimit == nil || c.UsageCount < *c.UsageLimit
+}
+
+// AppliesTo reports whether the coupon discounts the product.
+func (c Coupon) AppliesTo(product *Product) bool {
+	if len(c.CategoryIDs) == 0 {
+		return true
+	}
+	if product.CategoryID == nil {
+		return false
+	}
+	for _, id := range c.CategoryIDs {
+		if id == *product.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+type PromotionType string
+
+const (
+	// PromotionBuyXGetY makes the cheapest FreeQuantity of every BuyQuantity
+	// qualifying units free.
+	PromotionBuyXGetY PromotionType = "buy_x_get_y"
+	// PromotionTieredPercentage takes the percentage of the highest tier
+	// reached off the qualifying products.
+	PromotionTieredPercentage PromotionType = "tiered_percentage"
+)
+
+// PromotionTier gives Percentage off once the qualifying products add up to
+// at least Threshold.
+type PromotionTier struct {
+	Threshold  decimal.Decimal `json:"threshold"`
+	Percentage decimal.Decimal `json:"percentage"`
+}
+
+// Promotion is a rule that is applied to every cart automatically. Only
+// products in CategoryID qualify, or all products if it is not set. Name is
+// shown to customers next to the discount.
+type Promotion struct {
+	Model
+	Name         string          `json:"name" gorm:"not null"`
+	Type         PromotionType   `json:"type" gorm:"not null"`
+	CategoryID   *uint           `json:"categoryId" gorm:"index"`
+	BuyQuantity  int             `json:"buyQuantity"`
+	FreeQuantity int             `json:"freeQuantity"`
+	Tiers        []PromotionTier `json:"tiers" gorm:"serializer:json"`
+	Active       bool            `json:"active" gorm:"not null;index"`
+}
+
+// AppliesTo reports whether the product qualifies for the promotion.
+func (p Promotion) AppliesTo(product *Product) bool {
+	return p.CategoryID == nil || (product.CategoryID != nil && *product.CategoryID == *p.CategoryID)
+}
+
+type OrderStatus string
+
+const (
+	// OrderPlaced orders were placed before checkout took payments.
+	OrderPlaced          OrderStatus = "placed"
+	OrderAwaitingPayment OrderStatus = "awaiting_payment"
+	OrderPaid            OrderStatus = "paid"
+	OrderRefunded        OrderStatus = "refunded"
+	// OrderCancelled orders were never paid, their stock was put back.
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// Order is created from a cart at checkout. Lines copy the product name and
+// price so the order does not change when the catalog does.
+type Order struct {
+	Model
+	UserID *uint       `json:"userId" gorm:"index"`
+	CartID uint        `json:"cartId"`
+	Status OrderStatus `json:"status" gorm:"not null;default:placed;index"`
+	// Subtotal is the sum of the lines, Discount what promotions and coupons
+	// took off it.
+	Subtotal decimal.Decimal `json:"subtotal" gorm:"type:decimal(10,2);"`
+	Discount decimal.Decimal `json:"discount" gorm:"type:decimal(10,2);"`
+	// Tax is included in Total, and in the prices when PricesIncludeTax is
+	// set. Taxes breaks it down per rate of Country and Region.
+	Tax              decimal.Decimal `json:"tax" gorm:"type:decimal(10,2);"`
+	PricesIncludeTax bool            `json:"pricesIncludeTax"`
+	Country          string          `json:"country"`
+	Region           string          `json:"region"`
+	Postcode         string          `json:"postcode"`
+	// ShippingMethod is the name of the shipping method, Shipping what it
+	// cost.
+	ShippingMethodID *uint           `json:"shippingMethodId"`
+	ShippingMethod   string          `json:"shippingMethod"`
+	Shipping         decimal.Decimal `json:"shipping" gorm:"type:decimal(10,2);"`
+	Total            decimal.Decimal `json:"total" gorm:"type:decimal(10,2);"`
+	// The addresses are copied from the address book, so editing it later
+	// does not change where the order went.
+	ShippingAddress PostalAddress `json:"shippingAddress" gorm:"embedded;embeddedPrefix:shipping_"`
+	BillingAddress  PostalAddress `json:"billingAddress" gorm:"embedded;embeddedPrefix:billing_"`
+	// Currency is what the order was placed and is priced in, ExchangeRate
+	// the rate from the base currency used at checkout.
+	Currency     string           `json:"currency" gorm:"size:3"`
+	ExchangeRate decimal.Decimal  `json:"exchangeRate" gorm:"type:decimal(18,8);"`
+	Lines        []OrderLine      `json:"lines"`
+	Coupons      []OrderCoupon    `json:"coupons"`
+	Promotions   []OrderPromotion `json:"promotions"`
+	Taxes        []OrderTax       `json:"taxes"`
+	Payments     []Payment        `json:"payments"`
+}
+
+type OrderLine struct {
+	ID          uint            `json:"id" gorm:"primarykey"`
+	OrderID     uint            `json:"orderId" gorm:"not null;index"`
+	ProductID   *uint           `json:"productId"`
+	ProductName string          `json:"productName"`
+	UnitPrice   decimal.Decimal `json:"unitPrice" gorm:"type:decimal(10,2);"`
+	Quantity    int             `json:"quantity"`
+	LineTotal   decimal.Decimal `json:"lineTotal" gorm:"type:decimal(10,2);"`
+}
+
+// OrderCoupon is a coupon as it was when the order was placed, so editing or
+// deleting the coupon later does not change what was paid.
+type OrderCoupon struct {
+	ID       uint            `json:"id" gorm:"primarykey"`
+	OrderID  uint            `json:"orderId" gorm:"not null;index"`
+	CouponID *uint           `json:"couponId"`
+	Code     string          `json:"code"`
+	Type     CouponType      `json:"type"`
+	Value    decimal.Decimal `json:"value" gorm:"type:decimal(10,2);"`
+	Discount decimal.Decimal `json:"discount" gorm:"type:decimal(10,2);"`
+}
+
+// OrderPromotion is a promotion that gave a discount on the order.
+type OrderPromotion struct {
+	ID          uint            `json:"id" gorm:"primarykey"`
+	OrderID     uint            `json:"orderId" gorm:"not null;index"`
+	PromotionID *uint           `json:"promotionId"`
+	Label       string          `json:"label"`
+	Discount    decimal.Decimal `json:"discount" gorm:"type:decimal(10,2);"`
+}
+
+// OrderTax is the tax of a single rate on the order.
+type OrderTax struct {
+	ID        uint            `json:"id" gorm:"primarykey"`
+	OrderID   uint            `json:"orderId" gorm:"not null;index"`
+	TaxRateID *uint           `json:"taxRateId"`
+	Name      string          `json:"name"`
+	Rate      decimal.Decimal `json:"rate" gorm:"type:decimal(5,2);"`
+	Net       decimal.Decimal `json:"net" gorm:"type:decimal(10,2);"`
+	Amount    decimal.Decimal `json:"amount" gorm:"type:decimal(10,2);"`
+}
+
+type PaymentStatus string
+
+const (
+	PaymentPending    PaymentStatus = "pending"
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentDeclined   PaymentStatus = "declined"
+	PaymentCaptured   PaymentStatus = "captured"
+	PaymentRefunded   PaymentStatus = "refunded"
+	PaymentVoided     PaymentStatus = "voided"
+	// PaymentFailed payments could not be sent to the provider.
+	PaymentFailed PaymentStatus = "failed"
+)
+
+// Payment is an attempt to pay for a cart at checkout. Declined and failed
+// attempts are kept, with no order. ProviderRef identifies the payment at
+// Provider, it is nil if the provider could not be reached.
+type Payment struct {
+	Model
+	CartID         uint            `json:"cartId" gorm:"not null;index"`
+	OrderID        *uint           `json:"orderId" gorm:"index"`
+	Provider       string          `json:"provider" gorm:"not null;uniqueIndex:idx_payments_provider_ref"`
+	ProviderRef    *string         `json:"providerRef" gorm:"uniqueIndex:idx_payments_provider_ref"`
+	Status         PaymentStatus   `json:"status" gorm:"not null;index"`
+	Amount         decimal.Decimal `json:"amount" gorm:"type:decimal(10,2);"`
+	Currency       string          `json:"currency" gorm:"size:3"`
+	RefundedAmount decimal.Decimal `json:"refundedAmount" gorm:"type:decimal(10,2);"`
+	// ActionURL is where the customer confirms a pending payment.
+	ActionURL     string `json:"actionUrl,omitempty"`
+	FailureReason string `json:"failureReason,omitempty"`
 }
@@ -0,0 +1,428 @@
+// Package openapi documents the API as an OpenAPI 3.1 document built from the
+// registered routes and the structs their handlers bind and return.
+//
+// Handlers describe their routes as Operations. Request structs are read like
+// Echo binds them: fields with a param tag are path parameters, fields with a
+// query tag query parameters and the remaining fields the JSON body. Validate
+// tags become the constraints of the schemas.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"store_backend/problem"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Version is the version of the OpenAPI specification the document follows.
+const Version = "3.1.0"
+
+// Operation documents a route. Path is the path of the route within its API
+// version, e.g. /products/:id, as registered with Echo. Request is the struct
+// the handler binds, Response what it returns with Status. Status defaults to
+// 200, or 204 for operations without a response.
+//
+// Deprecated is when the operation was deprecated and Sunset when it is going
+// to be removed, if that is known. Deprecated operations are marked as such
+// in the document.
+type Operation struct {
+	Method      string
+	Version     string
+	Path        string
+	Summary     string
+	Description string
+	Request     any
+	Response    any
+	Status      int
+	Deprecated  time.Time
+	Sunset      time.Time
+}
+
+// Route returns the path the operation is registered with, e.g.
+// /v1/products/:id.
+func (op Operation) Route() string {
+	if op.Version == "" {
+		return op.Path
+	}
+	return "/" + op.Version + op.Path
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security"`
+}
+
+// PathItem maps the lowercase methods of a path to their operations.
+type PathItem map[string]*DocumentedOperation
+
+type DocumentedOperation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+	Sunset      string              `json:"x-sunset,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+//go:embed redoc.html
+var redoc []byte
+
+// Spec collects the operations of the API and serves the document built
+// from them.
+type Spec struct {
+	info       Info
+	operations []Operation
+	document   []byte
+}
+
+func New(info Info) *Spec {
+	return &Spec{info: info}
+}
+
+// Add documents operations.
+func (s *Spec) Add(operations ...Operation) {
+	s.operations = append(s.operations, operations...)
+}
+
+// RegisterRoutes serves the document at /openapi.json and a page rendering
+// it at /docs. The document is available once it was built.
+func (s *Spec) RegisterRoutes(e *echo.Echo) error {
+	e.GET("/openapi.json", s.ServeDocument)
+	e.GET("/docs", s.ServeDocs)
+
+	s.Add(
+		Operation{Method: http.MethodGet, Path: "/openapi.json", Summary: "Get the OpenAPI document", Response: map[string]any{}},
+		Operation{Method: http.MethodGet, Path: "/docs", Summary: "Read the API documentation", Description: "An HTML page rendering the OpenAPI document.", Status: http.StatusOK},
+	)
+
+	return nil
+}
+
+func (s *Spec) ServeDocument(c echo.Context) error {
+	if s.document == nil {
+		return problem.New(http.StatusServiceUnavailable, "API documentation is not available")
+	}
+	return c.JSONBlob(http.StatusOK, s.document)
+}
+
+func (s *Spec) ServeDocs(c echo.Context) error {
+	return c.HTMLBlob(http.StatusOK, redoc)
+}
+
+// Build builds the document of routes. Every route must be documented by an
+// operation and every operation must belong to a route, otherwise Build
+// returns an error naming them.
+func (s *Spec) Build(routes []*echo.Route) error {
+	operations := map[string]Operation{}
+	for _, op := range s.operations {
+		operations[op.Method+" "+op.Route()] = op
+	}
+
+	doc := Document{
+		OpenAPI: Version,
+		Info:    s.info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+				"apiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				"cartToken":  {Type: "apiKey", In: "header", Name: "X-Cart-Token"},
+			},
+		},
+		// Requests without credentials are served with the permissions of
+		// guests, who use their carts with the cart's token.
+		Security: []map[string][]string{{}, {"bearerAuth": {}}, {"apiKeyAuth": {}}, {"cartToken": {}}},
+	}
+
+	schemas := newSchemas()
+	problemSchema := schemas.of(reflect.TypeOf(problem.Details{}))
+	ids := operationIDs{}
+
+	var undocumented []string
+	for _, route := range routes {
+		if route.Method == echo.RouteNotFound {
+			continue
+		}
+
+		key := route.Method + " " + route.Path
+		op, ok := operations[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+			continue
+		}
+		delete(operations, key)
+
+		documented := document(schemas, route, op)
+		documented.OperationID = ids.next(route, op)
+
+		documented.Responses["default"] = Response{
+			Description: "Problem",
+			Content:     map[string]MediaType{problem.ContentType: {Schema: problemSchema}},
+		}
+
+		path := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = documented
+	}
+
+	var errs []string
+	if len(undocumented) > 0 {
+		errs = append(errs, "routes without a spec entry: "+strings.Join(undocumented, ", "))
+	}
+	if len(operations) > 0 {
+		stale := make([]string, 0, len(operations))
+		for key := range operations {
+			stale = append(stale, key)
+		}
+		slices.Sort(stale)
+		errs = append(errs, "spec entries without a route: "+strings.Join(stale, ", "))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("openapi: %s", strings.Join(errs, "; "))
+	}
+
+	doc.Components.Schemas = schemas.components
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	s.document = encoded
+	return nil
+}
+
+func document(schemas *schemas, route *echo.Route, op Operation) *DocumentedOperation {
+	documented := &DocumentedOperation{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        []string{tag(op.Path)},
+		Deprecated:  !op.Deprecated.IsZero(),
+		Responses:   map[string]Response{},
+	}
+	if !op.Sunset.IsZero() {
+		documented.Sunset = op.Sunset.UTC().Format(time.DateOnly)
+	}
+	if documented.Summary == "" {
+		documented.Summary = sentence(handlerName(route.Name))
+	}
+
+	var request reflect.Type
+	if op.Request != nil {
+		request = deref(reflect.TypeOf(op.Request))
+	}
+
+	for _, segment := range strings.Split(route.Path, "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			parameter := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+			if f, ok := field(request, "param", name); ok {
+				parameter.Schema = schemas.of(f.Type)
+				constrain(parameter.Schema, f.Type, f.Tag.Get("validate"))
+			}
+			documented.Parameters = append(documented.Parameters, parameter)
+		}
+	}
+
+	if request != nil {
+		for i := range request.NumField() {
+			f := request.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("query"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			parameter := Parameter{Name: name, In: "query", Schema: schemas.of(f.Type)}
+			parameter.Required = constrain(parameter.Schema, f.Type, f.Tag.Get("validate"))
+			documented.Parameters = append(documented.Parameters, parameter)
+		}
+
+		// Requests made of parameters only have no body.
+		if body := schemas.object(request); len(body.Properties) > 0 {
+			documented.RequestBody = &RequestBody{
+				Required: len(body.Required) > 0,
+				Content:  map[string]MediaType{echo.MIMEApplicationJSON: {Schema: schemas.of(request)}},
+			}
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+		if op.Response == nil {
+			status = http.StatusNoContent
+		}
+	}
+	response := Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		response.Content = map[string]MediaType{
+			echo.MIMEApplicationJSON: {Schema: schemas.of(reflect.TypeOf(op.Response))},
+		}
+	}
+	documented.Responses[fmt.Sprint(status)] = response
+
+	return documented
+}
+
+// operationIDs names operations after their handler methods. Methods of
+// different handlers with the same name are told apart by their handler, and
+// the operations of versions after the first by their version, e.g.
+// getProductsV2.
+type operationIDs struct {
+	first string
+	taken map[string]bool
+}
+
+func (ids *operationIDs) next(route *echo.Route, op Operation) string {
+	if ids.taken == nil {
+		ids.taken = map[string]bool{}
+	}
+	if ids.first == "" {
+		ids.first = op.Version
+	}
+
+	suffix := ""
+	if op.Version != "" && op.Version != ids.first {
+		suffix = strings.ToUpper(op.Version)
+	}
+
+	id := lowerFirst(handlerName(route.Name)) + suffix
+	if ids.taken[id] {
+		id = receiver(route.Name) + handlerName(route.Name) + suffix
+	}
+	ids.taken[id] = true
+	return id
+}
+
+// field returns the field of struct t whose tag key names name.
+func field(t reflect.Type, key string, name string) (reflect.StructField, bool) {
+	if t == nil || t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if tagName, _, _ := strings.Cut(f.Tag.Get(key), ","); tagName == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// openAPIPath turns Echo path parameters into OpenAPI ones, e.g. /products/:id
+// becomes /products/{id}.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// tag groups operations by the first segment of their path.
+func tag(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return strings.TrimSuffix(segment, ".json")
+}
+
+// handlerName returns the name of the method handling a route, e.g.
+// GetProducts for store_backend/handlers.(*ProductsHandler).GetProducts-fm.
+func handlerName(route string) string {
+	route = strings.TrimSuffix(route, "-fm")
+	return route[strings.LastIndex(route, ".")+1:]
+}
+
+// receiver returns the name of the handler a route belongs to, e.g. products
+// for store_backend/handlers.(*ProductsHandler).GetProducts-fm.
+func receiver(route string) string {
+	_, name, _ := strings.Cut(route, "(*")
+	name, _, _ = strings.Cut(name, ")")
+	return lowerFirst(strings.TrimSuffix(name, "Handler"))
+}
+
+// sentence turns a method name into a summary, e.g. GetAPIKeys becomes
+// "Get API keys".
+func sentence(name string) string {
+	runes := []rune(name)
+
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		lowerBefore := unicode.IsLower(runes[i-1])
+		lowerAfter := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsUpper(runes[i]) && (lowerBefore || lowerAfter) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	for i := 1; i < len(words); i++ {
+		if word := words[i]; len(word) < 2 || strings.ToUpper(word) != word {
+			words[i] = strings.ToLower(word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,293 @@
+package openapi
+
+import (
+	"reflect"
+	"slices"
+	"store_backend/problem"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Schema is a JSON Schema as used by OpenAPI 3.1. Type is a string, or a list
+// of strings for nullable values.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// wellKnown are types that are not encoded as their Go structure. Decimals
+// are encoded as strings but accepted as numbers too.
+var wellKnown = map[reflect.Type]Schema{
+	reflect.TypeOf(time.Time{}):       {Type: "string", Format: "date-time"},
+	reflect.TypeOf(decimal.Decimal{}): {Type: []string{"string", "number"}, Format: "decimal"},
+}
+
+// componentNames overrides the names of component schemas, which are the
+// names of their Go types otherwise.
+var componentNames = map[reflect.Type]string{
+	reflect.TypeOf(problem.Details{}): "Problem",
+}
+
+// schemas turns Go types into schemas the way encoding/json encodes them.
+// Named structs become component schemas that are referenced by name.
+type schemas struct {
+	components map[string]*Schema
+	types      map[string]reflect.Type
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, types: map[string]reflect.Type{}}
+}
+
+func (s *schemas) of(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		return nullable(s.of(t.Elem()))
+	}
+	if known, ok := wellKnown[t]; ok {
+		return &known
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+
+	// Interfaces can hold anything.
+	return &Schema{}
+}
+
+// ref returns a reference to the component schema of a named struct, adding
+// it on first use.
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name := s.name(t)
+	if _, ok := s.components[name]; !ok {
+		// The placeholder ends the recursion of self-referencing types.
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// name returns the component name of t. Generic types are named after their
+// type arguments, e.g. PaginatedResponseOfProduct, and types whose name is
+// taken by a type in another package are prefixed with their package.
+func (s *schemas) name(t reflect.Type) string {
+	if name, ok := componentNames[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if base, args, ok := strings.Cut(name, "["); ok {
+		name = base + "Of"
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			name += arg[strings.LastIndex(arg, ".")+1:]
+		}
+	}
+
+	if other, ok := s.types[name]; ok && other != t {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.types[name] = t
+	return name
+}
+
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(t, schema)
+	return schema
+}
+
+// fields adds the fields of struct t to schema. Fields bound from the path or
+// query of a request are not part of its body and left out, fields of
+// embedded structs are added as if they belonged to t.
+func (s *schemas) fields(t reflect.Type, schema *Schema) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		tag, hasTag := f.Tag.Lookup("json")
+		if !hasTag && (f.Tag.Get("param") != "" || f.Tag.Get("query") != "") {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			if embedded := deref(f.Type); embedded.Kind() == reflect.Struct {
+				s.fields(embedded, schema)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := s.of(f.Type)
+		if constrain(property, f.Type, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// constrain adds the rules of a validate tag to the schema of a value of type
+// t and reports whether the value is required. Rules after dive apply to the
+// items of a list.
+func constrain(schema *Schema, t reflect.Type, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+
+	t = deref(t)
+	kind := t.Kind()
+	if _, ok := wellKnown[t]; ok {
+		// Well-known types are encoded as strings, their rules are not
+		// about their length.
+		kind = reflect.Struct
+	}
+
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "dive":
+			if schema.Items != nil {
+				constrain(schema.Items, t.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "required":
+			required = true
+		case "min", "max", "len":
+			limit(schema, kind, name, param)
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				if n, err := strconv.Atoi(value); err == nil && kind != reflect.String {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, value)
+				}
+			}
+		case "email":
+			schema.Format = "email"
+		case "alpha":
+			schema.Pattern = "^[a-zA-Z]+$"
+		case "e164":
+			schema.Pattern = `^\+[1-9]\d{1,14}$`
+		case "positive":
+			schema.Description = "Must be positive."
+		case "nonnegative":
+			schema.Description = "Cannot be negative."
+		}
+	}
+
+	return required
+}
+
+// limit adds a min, max or len rule, which limits the length of text, the
+// number of items of lists and the value of numbers.
+func limit(schema *Schema, kind reflect.Kind, rule string, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	var lower, upper **int
+	switch kind {
+	case reflect.String:
+		lower, upper = &schema.MinLength, &schema.MaxLength
+	case reflect.Slice, reflect.Array, reflect.Map:
+		lower, upper = &schema.MinItems, &schema.MaxItems
+	case reflect.Struct:
+		return
+	default:
+		if rule != "max" {
+			schema.Minimum = ptr(n)
+		}
+		if rule != "min" {
+			schema.Maximum = ptr(n)
+		}
+		return
+	}
+
+	if rule != "max" {
+		*lower = ptr(int(n))
+	}
+	if rule != "min" {
+		*upper = ptr(int(n))
+	}
+}
+
+// nullable allows null in place of the value of schema.
+func nullable(schema *Schema) *Schema {
+	switch typ := schema.Type.(type) {
+	case string:
+		schema.Type = []string{typ, "null"}
+		return schema
+	case []string:
+		schema.Type = append(slices.Clone(typ), "null")
+		return schema
+	case nil:
+		if schema.Ref == "" {
+			return schema
+		}
+	}
+	return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
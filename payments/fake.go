@@ -0,0 +1,115 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/shopspring/decimal"
+)
+
+// Payment method tokens understood by the fake provider. Any other token is
+// authorized.
+const (
+	FakeMethodDecline = "fake_decline"
+	FakeMethodPending = "fake_3ds"
+	FakeMethodError   = "fake_error"
+)
+
+// FakeSignatureHeader carries the hex encoded HMAC-SHA256 of the webhook body.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeProvider simulates a payment provider without any network calls. The
+// payment method token decides the outcome of an authorization; pending
+// payments are completed by posting a signed event to the webhook:
+//
+//	{"reference": "fake_...", "status": "authorized"}
+type FakeProvider struct {
+	secret []byte
+}
+
+func NewFakeProvider(secret []byte) *FakeProvider {
+	return &FakeProvider{secret: secret}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Authorize(_ context.Context, req AuthorizeRequest) (Result, error) {
+	ref, err := fakeReference()
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch req.Method {
+	case FakeMethodDecline:
+		return Result{ProviderRef: ref, Status: StatusDeclined, Reason: "card_declined"}, nil
+	case FakeMethodPending:
+		return Result{ProviderRef: ref, Status: StatusPending, ActionURL: "https://fake-psp.invalid/3ds/" + ref}, nil
+	case FakeMethodError:
+		return Result{}, errors.New("fake provider unavailable")
+	}
+
+	return Result{ProviderRef: ref, Status: StatusAuthorized}, nil
+}
+
+func (p *FakeProvider) Capture(_ context.Context, providerRef string, _ decimal.Decimal) (Result, error) {
+	return Result{ProviderRef: providerRef, Status: StatusCaptured}, nil
+}
+
+func (p *FakeProvider) Refund(_ context.Context, providerRef string, _ decimal.Decimal) (Result, error) {
+	return Result{ProviderRef: providerRef, Status: StatusRefunded}, nil
+}
+
+func (p *FakeProvider) Void(_ context.Context, providerRef string) (Result, error) {
+	return Result{ProviderRef: providerRef, Status: StatusVoided}, nil
+}
+
+func (p *FakeProvider) ParseWebhook(header http.Header, body []byte) (Event, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.sign(body)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var payload struct {
+		Reference string `json:"reference"`
+		Status    Status `json:"status"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Reference == "" {
+		return Event{}, ErrInvalidEvent
+	}
+
+	switch payload.Status {
+	case StatusAuthorized, StatusDeclined:
+	default:
+		return Event{}, ErrInvalidEvent
+	}
+
+	return Event{ProviderRef: payload.Reference, Status: payload.Status, Reason: payload.Reason}, nil
+}
+
+// Sign returns the signature header value of a webhook body.
+func (p *FakeProvider) Sign(body []byte) string {
+	return hex.EncodeToString(p.sign(body))
+}
+
+func (p *FakeProvider) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func fakeReference() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "fake_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,86 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"store_backend/environment"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidEvent     = errors.New("invalid webhook event")
+)
+
+// Status is the state of a payment at the provider.
+type Status string
+
+const (
+	// StatusPending payments wait for the customer, for example to confirm
+	// the payment with their bank. The outcome arrives through the webhook.
+	StatusPending    Status = "pending"
+	StatusAuthorized Status = "authorized"
+	StatusDeclined   Status = "declined"
+	StatusCaptured   Status = "captured"
+	StatusRefunded   Status = "refunded"
+	StatusVoided     Status = "voided"
+)
+
+// AuthorizeRequest asks the provider to hold Amount on the customer's payment
+// method. Method is the token the frontend got from the provider. Reference
+// identifies the payment on our side.
+type AuthorizeRequest struct {
+	Reference string
+	Amount    decimal.Decimal
+	Currency  string
+	Method    string
+}
+
+// Result is the answer of the provider. ActionURL is where the customer
+// confirms a pending payment, Reason why a payment was declined.
+type Result struct {
+	ProviderRef string
+	Status      Status
+	ActionURL   string
+	Reason      string
+}
+
+// Event is a change of a payment reported by the provider through the
+// webhook.
+type Event struct {
+	ProviderRef string
+	Status      Status
+	Reason      string
+}
+
+// Provider is a payment service provider. Declines are results, not errors:
+// an error means the provider could not be reached or refused the request.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, providerRef string, amount decimal.Decimal) (Result, error)
+	Refund(ctx context.Context, providerRef string, amount decimal.Decimal) (Result, error)
+	Void(ctx context.Context, providerRef string) (Result, error)
+	// ParseWebhook verifies the signature of a callback and returns the
+	// event it reports.
+	ParseWebhook(header http.Header, body []byte) (Event, error)
+}
+
+// NewProvider returns the provider configured with PAYMENT_PROVIDER.
+func NewProvider(env environment.Environment) Provider {
+	if len(env.PaymentWebhookSecret) == 0 {
+		panic(errors.New("missing env variable: PAYMENT_WEBHOOK_SECRET"))
+	}
+
+	switch env.PaymentProvider {
+	case "fake":
+		if env.ENV == environment.Production {
+			panic(errors.New("the fake payment provider cannot be used in production"))
+		}
+		return NewFakeProvider(env.PaymentWebhookSecret)
+	}
+	panic(fmt.Errorf("invalid payment provider: %s", env.PaymentProvider))
+}
@@ -0,0 +1,167 @@
+package pricing
+
+import (
+	"fmt"
+	"regexp"
+	"store_backend/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency is a currency prices are shown in. Rate is how many units of it
+// one unit of the base currency is worth.
+type Currency struct {
+	Code string          `json:"code"`
+	Rate decimal.Decimal `json:"rate"`
+	// base is the code of the base currency.
+	base string
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExchangeRateError is a rate rejected by ValidateExchangeRates. Format
+// describes the problem with the rate's currency Code as its only argument.
+type ExchangeRateError struct {
+	Format string
+	Code   string
+}
+
+func (e *ExchangeRateError) Error() string {
+	return fmt.Sprintf(e.Format, e.Code)
+}
+
+// ValidateExchangeRates checks that rates are keyed by ISO 4217 style codes
+// other than the base currency and are all positive. It returns an
+// ExchangeRateError for the first rate that is not.
+func ValidateExchangeRates(base string, rates map[string]decimal.Decimal) error {
+	for code, rate := range rates {
+		if !currencyCode.MatchString(code) {
+			return &ExchangeRateError{Format: "Invalid currency code %q", Code: code}
+		}
+		if code == base {
+			return &ExchangeRateError{Format: "The base currency %s has no exchange rate", Code: code}
+		}
+		if !rate.IsPositive() {
+			return &ExchangeRateError{Format: "Exchange rate of %s must be positive", Code: code}
+		}
+	}
+	return nil
+}
+
+// BaseCurrency returns the currency all prices are stored in.
+func BaseCurrency(code string) Currency {
+	return Currency{Code: code, Rate: decimal.NewFromInt(1), base: code}
+}
+
+// NewCurrency returns a currency worth rate units per unit of the base
+// currency.
+func NewCurrency(code string, rate decimal.Decimal, base string) Currency {
+	return Currency{Code: code, Rate: rate, base: base}
+}
+
+// IsBase reports whether c is the base currency. A currency whose rate
+// happens to be 1 still has prices of its own.
+func (c Currency) IsBase() bool {
+	return c.Code == c.base
+}
+
+// Convert converts an amount in the base currency.
+func (c Currency) Convert(amount decimal.Decimal) decimal.Decimal {
+	if c.IsBase() {
+		return amount
+	}
+	return amount.Mul(c.Rate).Round(Places)
+}
+
+// PriceOf returns the product's price in the currency: its fixed price in the
+// currency if it has one, otherwise its converted base price.
+func (c Currency) PriceOf(product *models.Product) decimal.Decimal {
+	for _, price := range product.Prices {
+		if price.Currency == c.Code {
+			return price.Price
+		}
+	}
+	return c.Convert(product.Price)
+}
+
+// convertCart returns a copy of the cart with its prices and the amounts of
+// its coupons in the currency. Lines use the product's fixed price in the
+// currency where there is one.
+func (c Currency) convertCart(cart *models.Cart) *models.Cart {
+	converted := *cart
+
+	converted.Items = make([]models.CartItem, len(cart.Items))
+	for i, item := range cart.Items {
+		if item.Product != nil {
+			item.UnitPrice = c.UnitPriceOf(item)
+		}
+		converted.Items[i] = item
+	}
+
+	converted.Coupons = make([]models.Coupon, len(cart.Coupons))
+	for i, coupon := range cart.Coupons {
+		if coupon.Type == models.CouponFixedAmount {
+			coupon.Value = c.Convert(coupon.Value)
+		}
+		coupon.MinCartValue = c.Convert(coupon.MinCartValue)
+		converted.Coupons[i] = coupon
+	}
+
+	return &converted
+}
+
+// UnitPriceOf returns the price of a cart line in the currency: the
+// product's fixed price in the currency if it has one, otherwise the line's
+// converted unit price.
+func (c Currency) UnitPriceOf(item models.CartItem) decimal.Decimal {
+	if item.Product == nil {
+		return c.Convert(item.UnitPrice)
+	}
+	for _, price := range item.Product.Prices {
+		if price.Currency == c.Code {
+			return price.Price
+		}
+	}
+	return c.Convert(item.UnitPrice)
+}
+
+// convertPromotions returns copies of the promotions with their thresholds in
+// the currency.
+func (c Currency) convertPromotions(promotions []models.Promotion) []models.Promotion {
+	converted := make([]models.Promotion, len(promotions))
+
+	for i, promotion := range promotions {
+		tiers := make([]models.PromotionTier, len(promotion.Tiers))
+		for j, tier := range promotion.Tiers {
+			tiers[j] = models.PromotionTier{Threshold: c.Convert(tier.Threshold), Percentage: tier.Percentage}
+		}
+		promotion.Tiers = tiers
+		converted[i] = promotion
+	}
+
+	return converted
+}
+
+// convertShippingMethods returns copies of the methods with their prices, and
+// the thresholds of price tiered methods, in the currency.
+func (c Currency) convertShippingMethods(methods []models.ShippingMethod) []models.ShippingMethod {
+	converted := make([]models.ShippingMethod, len(methods))
+
+	for i, method := range methods {
+		method.Price = c.Convert(method.Price)
+
+		tiers := make([]models.ShippingTier, len(method.Tiers))
+		for j, tier := range method.Tiers {
+			tier.Price = c.Convert(tier.Price)
+			if method.Type == models.ShippingPriceTiered {
+				tier.Threshold = c.Convert(tier.Threshold)
+			}
+			tiers[j] = tier
+		}
+		method.Tiers = tiers
+
+		converted[i] = method
+	}
+
+	return converted
+}
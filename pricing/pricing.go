@@ -0,0 +1,259 @@
+package pricing
+
+import (
+	"fmt"
+	"log"
+	"store_backend/environment"
+	"store_backend/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Places is the number of decimal places all monetary amounts are rounded to.
+const Places = 2
+
+type LineTotal struct {
+	ItemID    uint            `json:"itemId"`
+	ProductID uint            `json:"productId"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice decimal.Decimal `json:"unitPrice"`
+	Total     decimal.Decimal `json:"total"`
+}
+
+// AppliedCoupon is the outcome of a coupon on the cart. Coupons that do not
+// apply are listed with a Reason and no discount.
+type AppliedCoupon struct {
+	CouponID uint              `json:"couponId"`
+	Code     string            `json:"code"`
+	Type     models.CouponType `json:"type"`
+	Value    decimal.Decimal   `json:"value"`
+	Discount decimal.Decimal   `json:"discount"`
+	Applied  bool              `json:"applied"`
+	Reason   string            `json:"reason,omitempty"`
+}
+
+type Totals struct {
+	Currency     string             `json:"currency"`
+	ExchangeRate decimal.Decimal    `json:"exchangeRate"`
+	Lines        []LineTotal        `json:"lines"`
+	Promotions   []AppliedPromotion `json:"promotions"`
+	Coupons      []AppliedCoupon    `json:"coupons"`
+	Taxes        []TaxLine          `json:"taxes"`
+	FreeShipping bool               `json:"freeShipping"`
+	// ShippingMethod is the quote of the cart's shipping method, nil if it
+	// has none or the method cannot deliver it.
+	ShippingMethod *ShippingQuote `json:"shippingMethod"`
+	// PricesIncludeTax tells whether Tax is already part of the prices and
+	// Subtotal, or is added on top of them in Total.
+	PricesIncludeTax bool            `json:"pricesIncludeTax"`
+	Subtotal         decimal.Decimal `json:"subtotal"`
+	Discount         decimal.Decimal `json:"discount"`
+	Tax              decimal.Decimal `json:"tax"`
+	Shipping         decimal.Decimal `json:"shipping"`
+	Total            decimal.Decimal `json:"total"`
+}
+
+// Rules are what a cart is priced with besides its own contents.
+type Rules struct {
+	Promotions []models.Promotion
+	TaxRates   []models.TaxRate
+	// ShippingMethods are the methods that deliver to the cart's destination.
+	ShippingMethods []models.ShippingMethod
+	// Currency is what the cart is priced in, the base currency if empty.
+	Currency Currency
+}
+
+// Calculator computes cart totals. All arithmetic is done with decimals and
+// rounded to Places only where an amount is shown to the customer.
+type Calculator struct {
+	promotions       PromotionSource
+	taxes            TaxSource
+	shipping         ShippingSource
+	pricesIncludeTax bool
+	taxRounding      TaxRounding
+	defaultCountry   string
+	baseCurrency     string
+}
+
+func NewCalculator(env environment.Environment, promotions PromotionSource, taxes TaxSource, shipping ShippingSource) *Calculator {
+	rounding := TaxRounding(env.TaxRounding)
+	if rounding != RoundPerLine && rounding != RoundPerTotal {
+		panic(fmt.Errorf("invalid tax rounding: %s", env.TaxRounding))
+	}
+
+	return &Calculator{
+		promotions:       promotions,
+		taxes:            taxes,
+		shipping:         shipping,
+		pricesIncludeTax: env.PricesIncludeTax,
+		taxRounding:      rounding,
+		defaultCountry:   env.DefaultCountry,
+		baseCurrency:     env.BaseCurrency,
+	}
+}
+
+// Calculate prices the cart in the currency with the promotions that are
+// running now, and the tax rates and shipping methods of its destination.
+func (c *Calculator) Calculate(cart *models.Cart, currency Currency) Totals {
+	return c.CalculateWith(cart, c.rules(cart, currency))
+}
+
+// rules loads the rules the cart is priced with. Rules that cannot be loaded
+// are left out.
+func (c *Calculator) rules(cart *models.Cart, currency Currency) Rules {
+	rules := Rules{Currency: currency}
+	var err error
+
+	if rules.Promotions, err = c.promotions.GetActive(); err != nil {
+		log.Printf("error getting promotions: %v", err)
+	}
+
+	country, region := c.Destination(cart)
+	if rules.TaxRates, err = c.taxes.GetRates(country, region); err != nil {
+		log.Printf("error getting tax rates: %v", err)
+	}
+
+	if rules.ShippingMethods, err = c.shipping.GetMethods(country, cart.Postcode); err != nil {
+		log.Printf("error getting shipping methods: %v", err)
+	}
+
+	return rules
+}
+
+// CalculateWith prices every item of the cart. Items whose product has been
+// deleted are not sold at checkout, so they are left out of the totals too.
+// Promotions are applied before coupons, tax is worked out on what is left
+// after both. Shipping is added on top and is not taxed.
+func (c *Calculator) CalculateWith(cart *models.Cart, rules Rules) Totals {
+	cart, rules = c.convert(cart, rules)
+
+	totals := c.price(cart, rules)
+	applyShipping(cart, rules.ShippingMethods, &totals)
+
+	totals.Total = totals.Subtotal.Sub(totals.Discount).Add(totals.Shipping)
+	if !c.pricesIncludeTax {
+		totals.Total = totals.Total.Add(totals.Tax)
+	}
+	totals.Total = totals.Total.Round(Places)
+
+	return totals
+}
+
+// convert returns the cart and the rules in the currency they are priced in.
+// Amounts of coupons, promotions and shipping methods are converted along
+// with the prices.
+func (c *Calculator) convert(cart *models.Cart, rules Rules) (*models.Cart, Rules) {
+	if rules.Currency.Code == "" {
+		rules.Currency = BaseCurrency(c.baseCurrency)
+	}
+	if !rules.Currency.IsBase() {
+		cart = rules.Currency.convertCart(cart)
+		rules.Promotions = rules.Currency.convertPromotions(rules.Promotions)
+		rules.ShippingMethods = rules.Currency.convertShippingMethods(rules.ShippingMethods)
+	}
+	return cart, rules
+}
+
+// price works out everything but shipping and the total.
+func (c *Calculator) price(cart *models.Cart, rules Rules) Totals {
+	totals := Totals{
+		Currency:         rules.Currency.Code,
+		ExchangeRate:     rules.Currency.Rate,
+		Lines:            make([]LineTotal, 0, len(cart.Items)),
+		Promotions:       make([]AppliedPromotion, 0),
+		Coupons:          make([]AppliedCoupon, 0, len(cart.Coupons)),
+		Taxes:            make([]TaxLine, 0),
+		PricesIncludeTax: c.pricesIncludeTax,
+		Subtotal:         decimal.Zero,
+		Discount:         decimal.Zero,
+		Tax:              decimal.Zero,
+		Shipping:         decimal.Zero,
+	}
+
+	for _, item := range cart.Items {
+		if item.Product == nil {
+			continue
+		}
+
+		line := LineTotal{
+			ItemID:    item.ID,
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			Total:     LinePrice(item.UnitPrice, item.Quantity),
+		}
+
+		totals.Lines = append(totals.Lines, line)
+		totals.Subtotal = totals.Subtotal.Add(line.Total)
+	}
+
+	applyPromotions(cart, rules.Promotions, &totals)
+
+	now := time.Now()
+	for _, coupon := range cart.Coupons {
+		applied := c.applyCoupon(cart, coupon, &totals, now)
+		totals.Coupons = append(totals.Coupons, applied)
+	}
+
+	c.applyTaxes(cart, rules.TaxRates, &totals)
+
+	return totals
+}
+
+// applyCoupon works out the discount of a single coupon and adds it to the
+// totals. The discount of all coupons together never exceeds the subtotal.
+func (c *Calculator) applyCoupon(cart *models.Cart, coupon models.Coupon, totals *Totals, now time.Time) AppliedCoupon {
+	applied := AppliedCoupon{
+		CouponID: coupon.ID,
+		Code:     coupon.Code,
+		Type:     coupon.Type,
+		Value:    coupon.Value,
+		Discount: decimal.Zero,
+	}
+
+	if !coupon.Usable(now) {
+		applied.Reason = "Coupon is not valid at this time"
+		return applied
+	}
+
+	if totals.Subtotal.LessThan(coupon.MinCartValue) {
+		applied.Reason = fmt.Sprintf("Cart value must be at least %s", coupon.MinCartValue.StringFixed(Places))
+		return applied
+	}
+
+	eligible := decimal.Zero
+	for _, item := range cart.Items {
+		if item.Product != nil && coupon.AppliesTo(item.Product) {
+			eligible = eligible.Add(LinePrice(item.UnitPrice, item.Quantity))
+		}
+	}
+
+	if eligible.IsZero() {
+		applied.Reason = "No products in the cart qualify for this coupon"
+		return applied
+	}
+
+	var discount decimal.Decimal
+	switch coupon.Type {
+	case models.CouponPercentage:
+		discount = eligible.Mul(coupon.Value).Div(decimal.NewFromInt(100)).Round(Places)
+	case models.CouponFixedAmount:
+		discount = decimal.Min(coupon.Value, eligible)
+	case models.CouponFreeShipping:
+		totals.FreeShipping = true
+		discount = decimal.Zero
+	}
+
+	discount = decimal.Min(discount, totals.Subtotal.Sub(totals.Discount))
+
+	applied.Discount = discount
+	applied.Applied = true
+	totals.Discount = totals.Discount.Add(discount)
+
+	return applied
+}
+
+func LinePrice(unitPrice decimal.Decimal, quantity int) decimal.Decimal {
+	return unitPrice.Mul(decimal.NewFromInt(int64(quantity))).Round(Places)
+}
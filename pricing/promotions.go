@@ -0,0 +1,102 @@
+package pricing
+
+import (
+	"slices"
+	"store_backend/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// PromotionSource provides the promotions that are currently running.
+type PromotionSource interface {
+	GetActive() ([]models.Promotion, error)
+}
+
+// AppliedPromotion is a promotion that gave the cart a discount.
+type AppliedPromotion struct {
+	PromotionID uint                 `json:"promotionId"`
+	Label       string               `json:"label"`
+	Type        models.PromotionType `json:"type"`
+	Discount    decimal.Decimal      `json:"discount"`
+}
+
+// applyPromotions evaluates every promotion against the cart and adds the
+// discounts of those that apply to the totals.
+func applyPromotions(cart *models.Cart, promotions []models.Promotion, totals *Totals) {
+	for _, promotion := range promotions {
+		var discount decimal.Decimal
+
+		switch promotion.Type {
+		case models.PromotionBuyXGetY:
+			discount = buyXGetYDiscount(cart, promotion)
+		case models.PromotionTieredPercentage:
+			discount = tieredDiscount(cart, promotion)
+		}
+
+		discount = decimal.Min(discount, totals.Subtotal.Sub(totals.Discount))
+		if !discount.IsPositive() {
+			continue
+		}
+
+		totals.Promotions = append(totals.Promotions, AppliedPromotion{
+			PromotionID: promotion.ID,
+			Label:       promotion.Name,
+			Type:        promotion.Type,
+			Discount:    discount,
+		})
+		totals.Discount = totals.Discount.Add(discount)
+	}
+}
+
+// buyXGetYDiscount makes the cheapest qualifying units free, FreeQuantity for
+// every BuyQuantity units in the cart.
+func buyXGetYDiscount(cart *models.Cart, promotion models.Promotion) decimal.Decimal {
+	if promotion.BuyQuantity <= 0 || promotion.FreeQuantity <= 0 {
+		return decimal.Zero
+	}
+
+	var units []decimal.Decimal
+	for _, item := range cart.Items {
+		if item.Product == nil || !promotion.AppliesTo(item.Product) {
+			continue
+		}
+		for range item.Quantity {
+			units = append(units, item.UnitPrice)
+		}
+	}
+
+	free := len(units) / promotion.BuyQuantity * promotion.FreeQuantity
+
+	slices.SortFunc(units, func(a, b decimal.Decimal) int { return a.Cmp(b) })
+
+	discount := decimal.Zero
+	for _, price := range units[:free] {
+		discount = discount.Add(price)
+	}
+
+	return discount.Round(Places)
+}
+
+// tieredDiscount takes the percentage of the highest tier the qualifying
+// products reach off their value.
+func tieredDiscount(cart *models.Cart, promotion models.Promotion) decimal.Decimal {
+	eligible := decimal.Zero
+	for _, item := range cart.Items {
+		if item.Product != nil && promotion.AppliesTo(item.Product) {
+			eligible = eligible.Add(LinePrice(item.UnitPrice, item.Quantity))
+		}
+	}
+
+	var reached *models.PromotionTier
+	for i, tier := range promotion.Tiers {
+		if eligible.GreaterThanOrEqual(tier.Threshold) && (reached == nil || tier.Threshold.GreaterThan(reached.Threshold)) {
+			reached = &promotion.Tiers[i]
+		}
+	}
+
+	if reached == nil || eligible.IsZero() {
+		return decimal.Zero
+	}
+
+	return eligible.Mul(reached.Percentage).Div(decimal.NewFromInt(100)).Round(Places)
+}
@@ -0,0 +1,128 @@
+package pricing
+
+import (
+	"slices"
+	"store_backend/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// ShippingSource provides the shipping methods that deliver to a destination.
+type ShippingSource interface {
+	GetMethods(country string, postcode string) ([]models.ShippingMethod, error)
+}
+
+// ShippingQuote is what a shipping method costs for a cart. Price is what the
+// customer pays, which is nothing with a free shipping coupon.
+type ShippingQuote struct {
+	MethodID uint                    `json:"methodId"`
+	Name     string                  `json:"name"`
+	Type     models.ShippingRateType `json:"type"`
+	Price    decimal.Decimal         `json:"price"`
+}
+
+// ShippingOptions quotes every shipping method that can deliver the cart to
+// its destination, cheapest first.
+func (c *Calculator) ShippingOptions(cart *models.Cart, currency Currency) []ShippingQuote {
+	return c.ShippingOptionsWith(cart, c.rules(cart, currency))
+}
+
+func (c *Calculator) ShippingOptionsWith(cart *models.Cart, rules Rules) []ShippingQuote {
+	cart, rules = c.convert(cart, rules)
+	totals := c.price(cart, rules)
+
+	quotes := make([]ShippingQuote, 0, len(rules.ShippingMethods))
+	for _, method := range rules.ShippingMethods {
+		if quote, ok := quoteShipping(cart, method, totals); ok {
+			quotes = append(quotes, quote)
+		}
+	}
+
+	slices.SortStableFunc(quotes, func(a, b ShippingQuote) int { return a.Price.Cmp(b.Price) })
+
+	return quotes
+}
+
+// applyShipping adds the cost of the cart's shipping method to the totals.
+// A method that no longer delivers to the destination, or cannot carry the
+// cart, is left out.
+func applyShipping(cart *models.Cart, methods []models.ShippingMethod, totals *Totals) {
+	if cart.ShippingMethodID == nil {
+		return
+	}
+
+	for _, method := range methods {
+		if method.ID != *cart.ShippingMethodID {
+			continue
+		}
+
+		if quote, ok := quoteShipping(cart, method, *totals); ok {
+			totals.ShippingMethod = &quote
+			totals.Shipping = quote.Price
+		}
+		return
+	}
+}
+
+// quoteShipping prices the method for the cart. Weight based methods are
+// priced by the weight of the cart, price tiered ones by its value after
+// discounts.
+func quoteShipping(cart *models.Cart, method models.ShippingMethod, totals Totals) (ShippingQuote, bool) {
+	weight := decimal.Zero
+	for _, item := range cart.Items {
+		if item.Product == nil {
+			continue
+		}
+
+		if method.MaxLength.IsPositive() && item.Product.LongestSide().GreaterThan(method.MaxLength) {
+			return ShippingQuote{}, false
+		}
+		weight = weight.Add(item.Product.Weight.Mul(decimal.NewFromInt(int64(item.Quantity))))
+	}
+
+	if method.MaxWeight.IsPositive() && weight.GreaterThan(method.MaxWeight) {
+		return ShippingQuote{}, false
+	}
+
+	var price decimal.Decimal
+	var ok bool
+
+	switch method.Type {
+	case models.ShippingFlat:
+		price, ok = method.Price, true
+	case models.ShippingWeightBased:
+		price, ok = tierPrice(method.Tiers, weight)
+	case models.ShippingPriceTiered:
+		price, ok = tierPrice(method.Tiers, totals.Subtotal.Sub(totals.Discount))
+	}
+
+	if !ok {
+		return ShippingQuote{}, false
+	}
+
+	if totals.FreeShipping {
+		price = decimal.Zero
+	}
+
+	return ShippingQuote{
+		MethodID: method.ID,
+		Name:     method.Name,
+		Type:     method.Type,
+		Price:    price,
+	}, true
+}
+
+// tierPrice returns the price of the highest tier the amount reaches.
+func tierPrice(tiers []models.ShippingTier, amount decimal.Decimal) (decimal.Decimal, bool) {
+	var best *models.ShippingTier
+	for i, tier := range tiers {
+		if amount.GreaterThanOrEqual(tier.Threshold) && (best == nil || tier.Threshold.GreaterThan(best.Threshold)) {
+			best = &tiers[i]
+		}
+	}
+
+	if best == nil {
+		return decimal.Zero, false
+	}
+	return best.Price, true
+}
@@ -0,0 +1,115 @@
+package pricing
+
+import (
+	"slices"
+	"store_backend/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// TaxRounding decides whether tax is rounded for every line or once for
+// every rate.
+type TaxRounding string
+
+const (
+	RoundPerLine  TaxRounding = "line"
+	RoundPerTotal TaxRounding = "total"
+)
+
+// TaxSource provides the tax rates of a destination.
+type TaxSource interface {
+	GetRates(country string, region string) ([]models.TaxRate, error)
+}
+
+// TaxLine is the tax of a single rate. Net is the amount the tax was
+// calculated on, without the tax.
+type TaxLine struct {
+	TaxRateID uint            `json:"taxRateId"`
+	Name      string          `json:"name"`
+	Rate      decimal.Decimal `json:"rate"`
+	Net       decimal.Decimal `json:"net"`
+	Amount    decimal.Decimal `json:"amount"`
+}
+
+// Destination returns the country and region the cart is taxed for.
+func (c *Calculator) Destination(cart *models.Cart) (string, string) {
+	if cart.Country == "" {
+		return c.defaultCountry, ""
+	}
+	return cart.Country, cart.Region
+}
+
+// applyTaxes works out the tax of every line with the rate of its product's
+// tax class and adds it to the totals. Discounts lower every line by the same
+// share, so they reduce the tax of every rate proportionally.
+func (c *Calculator) applyTaxes(cart *models.Cart, rates []models.TaxRate, totals *Totals) {
+	ratesByClass := map[uint]models.TaxRate{}
+	for _, rate := range rates {
+		// A rate for the region wins over the rate for the whole country.
+		if current, ok := ratesByClass[rate.TaxClassID]; !ok || current.Region == "" {
+			ratesByClass[rate.TaxClassID] = rate
+		}
+	}
+
+	share := decimal.NewFromInt(1)
+	if totals.Subtotal.IsPositive() {
+		share = totals.Subtotal.Sub(totals.Discount).Div(totals.Subtotal)
+	}
+
+	taxes := map[uint]*TaxLine{}
+	gross := map[uint]decimal.Decimal{}
+
+	for _, item := range cart.Items {
+		if item.Product == nil {
+			continue
+		}
+
+		classID := item.Product.EffectiveTaxClassID()
+		if classID == nil {
+			continue
+		}
+		rate, ok := ratesByClass[*classID]
+		if !ok {
+			continue
+		}
+
+		line, ok := taxes[rate.ID]
+		if !ok {
+			line = &TaxLine{TaxRateID: rate.ID, Name: rate.Name, Rate: rate.Rate, Amount: decimal.Zero}
+			taxes[rate.ID] = line
+		}
+
+		base := LinePrice(item.UnitPrice, item.Quantity).Mul(share)
+		tax := c.taxOf(base, rate.Rate)
+		if c.taxRounding == RoundPerLine {
+			tax = tax.Round(Places)
+		}
+
+		line.Amount = line.Amount.Add(tax)
+		gross[rate.ID] = gross[rate.ID].Add(base)
+	}
+
+	for id, line := range taxes {
+		line.Amount = line.Amount.Round(Places)
+		line.Net = gross[id].Round(Places)
+		if c.pricesIncludeTax {
+			line.Net = line.Net.Sub(line.Amount)
+		}
+
+		totals.Taxes = append(totals.Taxes, *line)
+		totals.Tax = totals.Tax.Add(line.Amount)
+	}
+
+	slices.SortFunc(totals.Taxes, func(a, b TaxLine) int { return int(a.TaxRateID) - int(b.TaxRateID) })
+}
+
+// taxOf returns the tax on amount at rate percent, taking it out of amount
+// when prices include tax.
+func (c *Calculator) taxOf(amount decimal.Decimal, rate decimal.Decimal) decimal.Decimal {
+	hundred := decimal.NewFromInt(100)
+
+	if c.pricesIncludeTax {
+		return amount.Mul(rate).Div(hundred.Add(rate))
+	}
+	return amount.Mul(rate).Div(hundred)
+}
@@ -0,0 +1,72 @@
+package problem
+
+import (
+	"reflect"
+	"store_backend/i18n"
+	"strings"
+)
+
+// validationMessages are the messages of validation rules, translated like
+// any other message. Rules whose message depends on what is validated, like
+// max, have entries per kind of value: "max.string" for text, "max.list" for
+// lists and "max" for numbers. {field} is replaced by the name of the field
+// and {param} by the argument of the rule.
+var validationMessages = map[string]string{
+	"":                 "{field} is invalid",
+	"type":             "{field} has the wrong type",
+	"required":         "{field} is required",
+	"required_with":    "{field} is required with {param}",
+	"required_without": "{field} is required without {param}",
+	"min":              "{field} must be at least {param}",
+	"min.string":       "{field} must be at least {param} characters long",
+	"min.list":         "{field} must have at least {param} items",
+	"max":              "{field} must be at most {param}",
+	"max.string":       "{field} must be at most {param} characters long",
+	"max.list":         "{field} must have at most {param} items",
+	"len":              "{field} must be {param}",
+	"len.string":       "{field} must be exactly {param} characters long",
+	"len.list":         "{field} must have exactly {param} items",
+	"gtefield":         "{field} must be greater than or equal to {param}",
+	"oneof":            "{field} must be one of: {param}",
+	"alpha":            "{field} must contain only letters",
+	"email":            "{field} must be a valid email address",
+	"e164":             "{field} must be a phone number in international format, e.g. +48123456789",
+	"permission":       "{field} must be a known permission",
+	"positive":         "{field} must be positive",
+	"nonnegative":      "{field} cannot be negative",
+}
+
+// message renders the message of a field error in locale. Rules without a
+// message of their own get a generic one.
+func message(locale string, fe FieldError) string {
+	template, ok := lookup(fe)
+	if !ok {
+		template = validationMessages[""]
+	}
+
+	param := fe.Param
+	if fe.Rule == "oneof" {
+		param = strings.Join(strings.Fields(param), ", ")
+	}
+
+	return strings.NewReplacer("{field}", fe.Field, "{param}", param).Replace(i18n.T(locale, template))
+}
+
+func lookup(fe FieldError) (string, bool) {
+	var suffix string
+	switch fe.kind {
+	case reflect.String:
+		suffix = ".string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		suffix = ".list"
+	}
+
+	if suffix != "" {
+		if template, ok := validationMessages[fe.Rule+suffix]; ok {
+			return template, true
+		}
+	}
+
+	template, ok := validationMessages[fe.Rule]
+	return template, ok
+}
@@ -0,0 +1,221 @@
+// Package problem reports errors to API clients as RFC 7807 problem details.
+//
+// Handlers return errors instead of writing error responses themselves.
+// Errors created with this package carry their status and detail; other
+// errors are mapped by From, so repository errors can be returned as they are.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"store_backend/i18n"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Problem types beyond the HTTP status of a problem. Other problems have a
+// type derived from their status, e.g. /problems/not-found.
+const (
+	TypeValidation = "/problems/validation-failed"
+	TypeConstraint = "/problems/constraint-violation"
+)
+
+// Details is an error with the fields of an RFC 7807 problem. Errors lists
+// the fields of validation problems, other extensions are written next to
+// the standard fields. The cause, if any, is logged but never shown to the
+// client.
+type Details struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Errors     []FieldError   `json:"errors,omitempty"`
+	Extensions map[string]any `json:"-"`
+
+	// format and args are what Detail is made of, to translate it.
+	format string
+	args   []any
+	cause  error
+}
+
+// New returns a problem with the given status, typed and titled after it.
+func New(status int, detail string) *Details {
+	return &Details{
+		Type:   typeOf(status),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		format: detail,
+	}
+}
+
+// Newf returns a problem whose detail is formatted, so that it can be
+// translated by its format.
+func Newf(status int, format string, args ...any) *Details {
+	p := New(status, fmt.Sprintf(format, args...))
+	p.format = format
+	p.args = args
+	return p
+}
+
+// Internal returns a 500 problem caused by err. Detail tells the client what
+// failed, err is only logged.
+func Internal(err error, detail string) *Details {
+	p := New(http.StatusInternalServerError, detail)
+	p.cause = err
+	return p
+}
+
+// Validation returns a 400 problem for a request that failed validation.
+func Validation(detail string) *Details {
+	p := New(http.StatusBadRequest, detail)
+	p.Type = TypeValidation
+	p.Title = "Validation failed"
+	return p
+}
+
+// With adds an extension member to the problem.
+func (p *Details) With(key string, value any) *Details {
+	if p.Extensions == nil {
+		p.Extensions = map[string]any{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p Details) MarshalJSON() ([]byte, error) {
+	type details Details
+
+	standard, err := json.Marshal(details(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return standard, err
+	}
+
+	extensions, err := json.Marshal(p.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both are JSON objects, so the extensions are spliced in before the
+	// closing brace of the standard fields.
+	return append(append(standard[:len(standard)-1], ','), extensions[1:]...), nil
+}
+
+func (p *Details) Error() string {
+	if p.cause != nil {
+		return fmt.Sprintf("%d %s: %v", p.Status, p.Detail, p.cause)
+	}
+	return fmt.Sprintf("%d %s", p.Status, p.Detail)
+}
+
+func (p *Details) Unwrap() error {
+	return p.cause
+}
+
+// From maps any error to a problem: problems are returned as they are, Echo
+// errors keep their status, and known repository and validation errors get
+// a matching status. Anything else is an internal error.
+func From(err error) *Details {
+	var p *Details
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var httpErr *echo.HTTPError
+	var validationErr validator.ValidationErrors
+
+	switch {
+	case errors.As(err, &httpErr):
+		p = New(httpErr.Code, "")
+		if msg, ok := httpErr.Message.(string); ok && msg != http.StatusText(httpErr.Code) {
+			p.Detail, p.format = msg, msg
+		}
+		if httpErr.Internal != nil {
+			p.cause = httpErr.Internal
+		}
+		return p
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return New(http.StatusNotFound, "Resource not found")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return constraint("Resource already exists")
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return constraint("Resource is referenced by or refers to a missing resource")
+	case errors.As(err, &validationErr):
+		return Validation("Invalid request format")
+	}
+
+	return Internal(err, "")
+}
+
+// ErrorHandler writes the errors returned by handlers and middleware as
+// problem details in the language of the request. Internal errors are logged
+// together with their cause.
+func ErrorHandler(logf func(format string, args ...any)) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		p := From(err).localize(i18n.Locale(c))
+		p.Instance = c.Request().URL.Path
+
+		if p.Status >= http.StatusInternalServerError {
+			logf("error handling %s %s: %v", c.Request().Method, p.Instance, err)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, ContentType)
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(p.Status)
+		} else {
+			err = c.JSON(p.Status, p)
+		}
+		if err != nil {
+			logf("error writing problem: %v", err)
+		}
+	}
+}
+
+// localize returns a copy of the problem with its title, detail and field
+// messages translated into locale.
+func (p *Details) localize(locale string) Details {
+	localized := *p
+	localized.Title = i18n.T(locale, p.Title)
+	if p.format != "" {
+		localized.Detail = i18n.Sprintf(locale, p.format, p.args...)
+	}
+
+	localized.Errors = make([]FieldError, len(p.Errors))
+	for i, fe := range p.Errors {
+		fe.Message = message(locale, fe)
+		localized.Errors[i] = fe
+	}
+
+	return localized
+}
+
+func constraint(detail string) *Details {
+	p := New(http.StatusConflict, detail)
+	p.Type = TypeConstraint
+	p.Title = "Constraint violation"
+	return p
+}
+
+// typeOf derives the type of a problem from its status, e.g. 404 becomes
+// /problems/not-found.
+func typeOf(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "about:blank"
+	}
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(text), " ", "-")
+}
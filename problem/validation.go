@@ -0,0 +1,152 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"store_backend/i18n"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// embedded stands in for the name of embedded structs in validation errors.
+// Their fields are named as if they belonged to the embedding struct, as in
+// JSON.
+const embedded = "~"
+
+// FieldError is a request field that failed validation. Field is the name of
+// the field in the request, e.g. shippingAddress.postcode, Rule the validation
+// it failed and Param the argument of the rule, if any.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+
+	kind reflect.Kind
+}
+
+// Invalid returns a validation problem listing the fields that failed
+// validation.
+func Invalid(fields ...FieldError) *Details {
+	for i := range fields {
+		fields[i].Message = message(i18n.Source, fields[i])
+	}
+
+	p := Validation("Invalid request format")
+	p.Errors = fields
+	return p
+}
+
+// FieldName names struct fields in validation errors the way clients send
+// them: by their json, query or param tag, falling back to the Go name.
+// Register it with validator.Validate.RegisterTagNameFunc.
+func FieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "query", "param"} {
+		name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	if f.Anonymous {
+		return embedded
+	}
+	return f.Name
+}
+
+// FromValidator turns the errors of validating v into a validation problem.
+// Other errors are returned as they are.
+func FromValidator(v any, err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fields := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		fields[i] = FieldError{
+			Field: fieldPath(fe.Namespace()),
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+			kind:  fe.Kind(),
+		}
+		if strings.HasSuffix(fe.Tag(), "field") || strings.HasPrefix(fe.Tag(), "required_") {
+			fields[i].Param = siblingName(reflect.TypeOf(v), fe.StructNamespace(), fe.Param())
+		}
+	}
+
+	return Invalid(fields...)
+}
+
+// FromBinding turns an error binding a request into a problem naming the
+// field that could not be bound, if it is known.
+func FromBinding(err error) *Details {
+	var bindingErr *echo.BindingError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &bindingErr):
+		return Invalid(FieldError{Field: bindingErr.Field, Rule: "type"})
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return Invalid(FieldError{Field: typeErr.Field, Rule: "type"})
+	}
+	return New(http.StatusBadRequest, "Invalid request format")
+}
+
+// fieldPath drops the name of the validated struct and of embedded structs
+// from a validator namespace.
+func fieldPath(namespace string) string {
+	segments := strings.Split(namespace, ".")[1:]
+
+	path := segments[:0]
+	for _, segment := range segments {
+		if segment != embedded {
+			path = append(path, segment)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// siblingName returns the request name of the field another field is
+// compared to, e.g. by required_without. The field is looked up next to the
+// one that failed, structNamespace being the Go path of the latter.
+func siblingName(root reflect.Type, structNamespace string, name string) string {
+	segments := strings.Split(structNamespace, ".")
+	parent := root
+
+	for _, segment := range segments[1 : len(segments)-1] {
+		parent = elem(parent)
+		if parent.Kind() != reflect.Struct {
+			return name
+		}
+		segment, _, _ = strings.Cut(segment, "[")
+		f, ok := parent.FieldByName(segment)
+		if !ok {
+			return name
+		}
+		parent = f.Type
+	}
+
+	parent = elem(parent)
+	if parent.Kind() != reflect.Struct {
+		return name
+	}
+	if f, ok := parent.FieldByName(name); ok {
+		return FieldName(f)
+	}
+	return name
+}
+
+// elem returns the type of the values behind pointers and in collections.
+func elem(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"store_backend/models"
+
+	"gorm.io/gorm"
+)
+
+type AddressRepository struct {
+	db *gorm.DB
+}
+
+func NewAddressRepository(db *gorm.DB) *AddressRepository {
+	return &AddressRepository{db: db}
+}
+
+func (r AddressRepository) GetAllByUser(userID uint) ([]models.Address, error) {
+	var addresses []models.Address
+
+	err := r.db.Scopes(
+		OwnedBy(userID),
+		OrderBy("id", "asc"),
+	).Find(&addresses).Error
+
+	return addresses, err
+}
+
+func (r AddressRepository) GetByID(id uint) (*models.Address, error) {
+	var address models.Address
+	if err := r.db.First(&address, id).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetDefaultShipping returns the user's default shipping address.
+func (r AddressRepository) GetDefaultShipping(userID uint) (*models.Address, error) {
+	var address models.Address
+	if err := r.db.Scopes(OwnedBy(userID)).Where("default_shipping = ?", true).First(&address).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetDefaultBilling returns the user's default billing address.
+func (r AddressRepository) GetDefaultBilling(userID uint) (*models.Address, error) {
+	var address models.Address
+	if err := r.db.Scopes(OwnedBy(userID)).Where("default_billing = ?", true).First(&address).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// Create adds the address to the user's address book. The first address of
+// a user becomes their default shipping and billing address.
+func (r AddressRepository) Create(address *models.Address) (*models.Address, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Address{}).Scopes(OwnedBy(address.UserID)).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			address.DefaultShipping = true
+			address.DefaultBilling = true
+		}
+
+		if err := clearDefaults(tx, address); err != nil {
+			return err
+		}
+
+		return tx.Create(address).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// Update saves the address. Making it a default takes the flag away from the
+// user's other addresses.
+func (r AddressRepository) Update(address *models.Address) (*models.Address, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaults(tx, address); err != nil {
+			return err
+		}
+
+		return tx.Save(address).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (r AddressRepository) Delete(id uint) error {
+	res := r.db.Delete(&models.Address{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// clearDefaults unsets the default flags the address is about to take on the
+// user's other addresses.
+func clearDefaults(tx *gorm.DB, address *models.Address) error {
+	others := func() *gorm.DB {
+		return tx.Model(&models.Address{}).Scopes(OwnedBy(address.UserID)).Where("id <> ?", address.ID)
+	}
+
+	if address.DefaultShipping {
+		if err := others().Update("default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+
+	if address.DefaultBilling {
+		if err := others().Update("default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
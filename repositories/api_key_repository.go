@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"store_backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often a key's LastUsedAt is written, so busy
+// integrations do not cause a write on every request.
+const lastUsedResolution = time.Minute
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r APIKeyRepository) GetAll() ([]models.APIKey, error) {
+	var keys []models.APIKey
+
+	err := r.db.Scopes(
+		OrderBy("created_at", "desc"),
+	).Find(&keys).Error
+
+	return keys, err
+}
+
+func (r APIKeyRepository) GetByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetActiveByHash returns the key with the given hash unless it has been
+// revoked or has expired.
+func (r APIKeyRepository) GetActiveByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+
+	err := r.db.
+		Where("key_hash = ? AND revoked_at IS NULL", keyHash).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r APIKeyRepository) Create(key *models.APIKey) (*models.APIKey, error) {
+	if err := r.db.Create(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r APIKeyRepository) UpdateScopes(id uint, scopes []string) (*models.APIKey, error) {
+	key, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = scopes
+	if err := r.db.Model(key).Select("scopes").Updates(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r APIKeyRepository) Revoke(id uint) (*models.APIKey, error) {
+	key, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := r.db.Model(key).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func (r APIKeyRepository) TouchLastUsed(id uint, now time.Time) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-lastUsedResolution)).
+		Update("last_used_at", now).Error
+}
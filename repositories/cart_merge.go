@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"store_backend/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrMergeSameCart = errors.New("cannot merge a cart into itself")
+
+// MergePolicy decides what happens to a product that is in both carts being
+// merged.
+type MergePolicy string
+
+const (
+	// MergeCombine adds the quantities of both carts together.
+	MergeCombine MergePolicy = "merge"
+	// MergeReplace throws away the target cart contents and keeps the source.
+	MergeReplace MergePolicy = "replace"
+	// MergeKeepNewest keeps the quantity of whichever line changed last.
+	MergeKeepNewest MergePolicy = "keep_newest"
+)
+
+func (p MergePolicy) Valid() bool {
+	switch p {
+	case MergeCombine, MergeReplace, MergeKeepNewest:
+		return true
+	}
+	return false
+}
+
+// Merge moves the contents of the source cart into the target cart and closes
+// the source cart. Products deleted from the catalog are dropped and lines are
+// cut down to the stock that can still be reserved.
+func (r CartRepository) Merge(targetID uint, sourceID uint, policy MergePolicy) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return r.merge(tx, targetID, sourceID, policy)
+	})
+}
+
+// AdoptGuestCart hands a guest cart over to a user who just signed in, given
+// the hash of the cart's token. The guest cart is merged into the user's most
+// recently used active cart, or becomes the user's cart if they have none. It
+// returns the ID of the cart the user should continue with.
+func (r CartRepository) AdoptGuestCart(userID uint, guestCartID uint, tokenHash string, policy MergePolicy) (uint, error) {
+	var cartID uint
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var guest models.Cart
+
+		if err := tx.First(&guest, guestCartID).Error; err != nil {
+			return err
+		}
+
+		if guest.UserID != nil {
+			if *guest.UserID != userID {
+				return gorm.ErrRecordNotFound
+			}
+			cartID = guest.ID
+			return nil
+		}
+
+		if guest.TokenHash == "" || guest.TokenHash != tokenHash {
+			return gorm.ErrRecordNotFound
+		}
+
+		var carts []models.Cart
+		err := tx.Scopes(OwnedBy(userID)).
+			Where("status = ? AND id <> ?", models.CartActive, guest.ID).
+			Order("updated_at DESC").
+			Limit(1).
+			Find(&carts).Error
+		if err != nil {
+			return err
+		}
+
+		if len(carts) == 0 {
+			cartID = guest.ID
+			return tx.Model(&guest).Updates(map[string]interface{}{"user_id": userID, "token_hash": ""}).Error
+		}
+
+		cartID = carts[0].ID
+		return r.merge(tx, cartID, guest.ID, policy)
+	})
+
+	return cartID, err
+}
+
+func (r CartRepository) merge(tx *gorm.DB, targetID uint, sourceID uint, policy MergePolicy) error {
+	if targetID == sourceID {
+		return ErrMergeSameCart
+	}
+
+	if _, err := findActiveCart(tx, targetID); err != nil {
+		return err
+	}
+
+	var target, source models.Cart
+	if err := tx.Scopes(WithItems()).First(&target, targetID).Error; err != nil {
+		return err
+	}
+	if err := tx.Scopes(WithItems()).First(&source, sourceID).Error; err != nil {
+		return err
+	}
+
+	// Closing the source cart first gives its reservations back, so the
+	// merged lines can reserve the same units again.
+	reason := fmt.Sprintf("merged into cart %d", targetID)
+	if err := transitionCart(tx, &source, models.CartMerged, reason); err != nil {
+		return err
+	}
+
+	quantities := map[uint]int{}
+	products := map[uint]*models.Product{}
+	lines := map[uint]*models.CartItem{}
+
+	for i := range target.Items {
+		item := &target.Items[i]
+		lines[item.ProductID] = item
+		if item.Product != nil && policy != MergeReplace {
+			quantities[item.ProductID] = item.Quantity
+		}
+	}
+
+	for _, item := range source.Items {
+		if item.Product == nil {
+			continue
+		}
+		products[item.ProductID] = item.Product
+
+		existing, inTarget := lines[item.ProductID]
+		switch {
+		case policy == MergeCombine:
+			quantities[item.ProductID] += item.Quantity
+		case policy == MergeKeepNewest && inTarget && existing.Product != nil && existing.UpdatedAt.After(item.UpdatedAt):
+			// The target line is newer, keep it as it is.
+		default:
+			quantities[item.ProductID] = item.Quantity
+		}
+	}
+
+	// Target lines that are not kept, including products deleted from the
+	// catalog, are removed.
+	for productID := range lines {
+		if _, ok := quantities[productID]; !ok {
+			quantities[productID] = 0
+		}
+	}
+
+	for productID, quantity := range quantities {
+		item, ok := lines[productID]
+		if !ok {
+			item = &models.CartItem{CartID: targetID, ProductID: productID}
+		}
+
+		if err := r.setMergedQuantity(tx, item, products[productID], quantity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMergedQuantity sets the quantity of a merged line, lowering it to what is
+// still in stock instead of failing the whole merge.
+func (r CartRepository) setMergedQuantity(tx *gorm.DB, item *models.CartItem, product *models.Product, quantity int) error {
+	err := r.setItemQuantity(tx, item, product, quantity)
+
+	var stockErr *InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		return err
+	}
+
+	return r.setItemQuantity(tx, item, product, min(quantity, item.ReservedQuantity+stockErr.Available))
+}
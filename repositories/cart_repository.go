@@ -1,22 +1,40 @@
 package repositories
 
 import (
+	"errors"
+	"fmt"
 	"store_backend/models"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrProductNotInCart = errors.New("product is not in cart")
+	ErrCartNotActive    = errors.New("cart is not active")
+)
+
+type InvalidCartTransitionError struct {
+	From models.CartStatus
+	To   models.CartStatus
+}
+
+func (e *InvalidCartTransitionError) Error() string {
+	return fmt.Sprintf("cannot move cart from %q to %q", e.From, e.To)
+}
+
 type CartRepository struct {
-	db *gorm.DB
+	db             *gorm.DB
+	reservationTTL time.Duration
 }
 
-func NewCartRepository(db *gorm.DB) *CartRepository {
-	return &CartRepository{db: db}
+func NewCartRepository(db *gorm.DB, reservationTTL time.Duration) *CartRepository {
+	return &CartRepository{db: db, reservationTTL: reservationTTL}
 }
 
-func (r CartRepository) GetAll() ([]models.Cart, error) {
+func (r CartRepository) GetAllByUser(userID uint) ([]models.Cart, error) {
 	var carts []models.Cart
-	if err := r.db.Preload("Products").Find(&carts).Error; err != nil {
+	if err := r.db.Scopes(WithItems(), WithCoupons(), OwnedBy(userID)).Find(&carts).Error; err != nil {
 		return nil, err
 	}
 	return carts, nil
@@ -26,7 +44,8 @@ func (r CartRepository) GetByID(id uint) (*models.Cart, error) {
 	var cart models.Cart
 
 	if err := r.db.Scopes(
-		WithProducts(),
+		WithItems(),
+		WithCoupons(),
 	).First(&cart, id).Error; err != nil {
 		return nil, err
 	}
@@ -35,7 +54,21 @@ func (r CartRepository) GetByID(id uint) (*models.Cart, error) {
 }
 
 func (r CartRepository) Create(cart *models.Cart) (*models.Cart, error) {
-	if err := r.db.Create(cart).Error; err != nil {
+	cart.Status = models.CartActive
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(cart).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.CartStatusChange{
+			CartID: cart.ID,
+			To:     cart.Status,
+			Reason: "cart created",
+		}).Error
+	})
+
+	if err != nil {
 		return nil, err
 	}
 	return cart, nil
@@ -48,89 +81,361 @@ func (r CartRepository) Update(cart *models.Cart) (*models.Cart, error) {
 	return cart, nil
 }
 
+// Delete marks an open cart as abandoned before removing it. Checked out carts
+// belong to an order and cannot be deleted.
 func (r CartRepository) Delete(id uint) error {
-	if err := r.db.Delete(&models.Cart{}, id).Error; err != nil {
-		return err
-	}
-	return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var cart models.Cart
+
+		if err := tx.First(&cart, id).Error; err != nil {
+			return err
+		}
+
+		switch cart.Status {
+		case models.CartActive, models.CartLocked:
+			if err := transitionCart(tx, &cart, models.CartAbandoned, "cart deleted"); err != nil {
+				return err
+			}
+		case models.CartCheckedOut:
+			return &InvalidCartTransitionError{From: cart.Status, To: models.CartAbandoned}
+		}
+
+		return tx.Delete(&cart).Error
+	})
 }
 
-func (r CartRepository) AddProduct(cartID uint, productID uint) error {
-	var cart models.Cart
-	var product models.Product
+func (r CartRepository) Transition(cartID uint, to models.CartStatus, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var cart models.Cart
 
-	if err := r.db.First(&cart, cartID).Error; err != nil {
-		return err
-	}
+		if err := tx.First(&cart, cartID).Error; err != nil {
+			return err
+		}
 
-	if err := r.db.First(&product, productID).Error; err != nil {
-		return err
+		return transitionCart(tx, &cart, to, reason)
+	})
+}
+
+func (r CartRepository) GetHistory(cartID uint) ([]models.CartStatusChange, error) {
+	var history []models.CartStatusChange
+
+	if err := r.db.First(&models.Cart{}, cartID).Error; err != nil {
+		return nil, err
 	}
 
-	cart.Products = append(cart.Products, product)
+	err := r.db.Where("cart_id = ?", cartID).Order("id").Find(&history).Error
 
-	if err := r.db.Save(&cart).Error; err != nil {
-		return err
+	return history, err
+}
+
+// SetDestination sets where the cart will be delivered to.
+func (r CartRepository) SetDestination(cartID uint, country string, region string, postcode string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := findActiveCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(cart).Select("country", "region", "postcode").
+			Updates(models.Cart{Country: country, Region: region, Postcode: postcode}).Error
+	})
+}
+
+// SetShippingMethod sets the shipping method the cart is delivered with, nil
+// clears it. Whether the method can deliver the cart is up to the caller.
+func (r CartRepository) SetShippingMethod(cartID uint, methodID *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := findActiveCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(cart).Update("shipping_method_id", methodID).Error
+	})
+}
+
+// AddProduct increments the quantity of the product in the cart, creating the
+// line at the current product price if the product is not in the cart yet.
+func (r CartRepository) AddProduct(cartID uint, productID uint, quantity int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+
+		if _, err := findActiveCart(tx, cartID); err != nil {
+			return err
+		}
+
+		if err := tx.First(&product, productID).Error; err != nil {
+			return err
+		}
+
+		item, err := findCartItem(tx, cartID, productID)
+		if err != nil {
+			return err
+		}
+
+		return r.setItemQuantity(tx, item, &product, item.Quantity+quantity)
+	})
+}
+
+// SetProductQuantity sets the quantity of the product in the cart. A quantity
+// of zero removes the line.
+func (r CartRepository) SetProductQuantity(cartID uint, productID uint, quantity int) error {
+	if quantity <= 0 {
+		return r.RemoveProduct(cartID, productID, 0)
 	}
 
-	return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+
+		if _, err := findActiveCart(tx, cartID); err != nil {
+			return err
+		}
+
+		if err := tx.First(&product, productID).Error; err != nil {
+			return err
+		}
+
+		item, err := findCartItem(tx, cartID, productID)
+		if err != nil {
+			return err
+		}
+
+		return r.setItemQuantity(tx, item, &product, quantity)
+	})
 }
 
-func (r CartRepository) RemoveProduct(cartID uint, productID uint) error {
+// RemoveProduct decreases the quantity of the product in the cart by quantity,
+// dropping the line once it reaches zero. A quantity of zero removes the whole
+// line.
+func (r CartRepository) RemoveProduct(cartID uint, productID uint, quantity int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := findActiveCart(tx, cartID); err != nil {
+			return err
+		}
+
+		item, err := findCartItem(tx, cartID, productID)
+		if err != nil {
+			return err
+		}
+		if item.ID == 0 {
+			return ErrProductNotInCart
+		}
+
+		if quantity <= 0 {
+			quantity = item.Quantity
+		}
+
+		return r.setItemQuantity(tx, item, nil, max(item.Quantity-quantity, 0))
+	})
+}
+
+func (r CartRepository) GetItems(cartID uint) ([]models.CartItem, error) {
 	var cart models.Cart
 
 	if err := r.db.Scopes(
-		WithProducts(),
+		WithItems(),
 	).First(&cart, cartID).Error; err != nil {
-		return err
+		return nil, err
 	}
 
-	for i, product := range cart.Products {
-		if product.ID == productID {
-			cart.Products = append(cart.Products[:i], cart.Products[i+1:]...)
-			break
+	return cart.Items, nil
+}
+
+func (r CartRepository) ClearCart(cartID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := findActiveCart(tx, cartID); err != nil {
+			return err
+		}
+
+		if err := releaseCartReservations(tx, cartID); err != nil {
+			return err
 		}
+
+		return tx.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error
+	})
+}
+
+// AbandonTokenlessGuestCarts abandons the open guest carts created before
+// guest carts had tokens. Nobody can use them any more, so their reservations
+// are released rather than held until they expire. It returns how many carts
+// were abandoned.
+func (r CartRepository) AbandonTokenlessGuestCarts() (int, error) {
+	var carts []models.Cart
+
+	err := r.db.
+		Where("user_id IS NULL AND COALESCE(token_hash, '') = ''").
+		Where("status IN ?", []models.CartStatus{models.CartActive, models.CartLocked}).
+		Find(&carts).Error
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range carts {
+			if err := transitionCart(tx, &carts[i], models.CartAbandoned, "guest cart without a token"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(carts), nil
+}
+
+// ReleaseExpiredReservations gives back the stock of every reservation that ran
+// out before now and returns how many cart items were affected.
+func (r CartRepository) ReleaseExpiredReservations(now time.Time) (int, error) {
+	var released int
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var items []models.CartItem
+
+		err := tx.Where("reserved_quantity > 0 AND reserved_until < ?", now).Find(&items).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range items {
+			if err := releaseReservation(tx, &items[i]); err != nil {
+				return err
+			}
+		}
+
+		released = len(items)
+		return nil
+	})
+
+	return released, err
+}
+
+// setItemQuantity changes the quantity of a cart line and moves its stock
+// reservation along with it. Adding units reserves the whole line again and
+// renews the expiry. A new line is priced from product; a quantity of zero
+// deletes the line.
+func (r CartRepository) setItemQuantity(tx *gorm.DB, item *models.CartItem, product *models.Product, quantity int) error {
+	reserved := quantity
+	reservedUntil := time.Now().Add(r.reservationTTL)
+	expiry := &reservedUntil
+
+	// Taking units out must not fail because a reservation has lapsed, so a
+	// decrease only shrinks what is still reserved and keeps its expiry.
+	if quantity < item.Quantity {
+		reserved = min(quantity, item.ReservedQuantity)
+		expiry = item.ReservedUntil
+	}
+	if reserved == 0 {
+		expiry = nil
 	}
 
-	if err := r.db.Save(&cart).Error; err != nil {
+	if err := reserveStock(tx, item.ProductID, reserved-item.ReservedQuantity); err != nil {
 		return err
 	}
 
-	return nil
+	if quantity == 0 {
+		if item.ID == 0 {
+			return nil
+		}
+		return tx.Delete(item).Error
+	}
+
+	if item.ID == 0 {
+		item.UnitPrice = product.Price
+	}
+
+	item.Quantity = quantity
+	item.ReservedQuantity = reserved
+	item.ReservedUntil = expiry
+
+	return tx.Save(item).Error
 }
 
-func (r CartRepository) GetProducts(cartID uint) ([]models.Product, error) {
-	var cart models.Cart
+// findCartItem returns the cart line for the product, or an unsaved line with
+// zero quantity if the product is not in the cart.
+func findCartItem(tx *gorm.DB, cartID uint, productID uint) (*models.CartItem, error) {
+	item := models.CartItem{CartID: cartID, ProductID: productID}
 
-	if err := r.db.Scopes(
-		WithProducts(),
-	).First(&cart, cartID).Error; err != nil {
+	err := tx.Where("cart_id = ? AND product_id = ?", cartID, productID).Limit(1).Find(&item).Error
+	if err != nil {
 		return nil, err
 	}
 
-	return cart.Products, nil
+	return &item, nil
 }
 
-func (r CartRepository) ClearCart(cartID uint) error {
+// findActiveCart loads the cart and fails with ErrCartNotActive unless its
+// contents may still be changed.
+func findActiveCart(tx *gorm.DB, cartID uint) (*models.Cart, error) {
 	var cart models.Cart
 
-	if err := r.db.First(&cart, cartID).Error; err != nil {
-		return err
+	if err := tx.First(&cart, cartID).Error; err != nil {
+		return nil, err
+	}
+
+	if cart.Status != models.CartActive {
+		return nil, ErrCartNotActive
 	}
 
-	cart.Products = []models.Product{}
+	return &cart, nil
+}
+
+// transitionCart moves the cart to the given status and records the change.
+// The update is guarded by the current status so that a concurrent transition
+// is reported instead of overwritten.
+func transitionCart(tx *gorm.DB, cart *models.Cart, to models.CartStatus, reason string) error {
+	if !cart.Status.CanTransitionTo(to) {
+		return &InvalidCartTransitionError{From: cart.Status, To: to}
+	}
 
-	if err := r.db.Save(&cart).Error; err != nil {
+	res := tx.Model(&models.Cart{}).
+		Where("id = ? AND status = ?", cart.ID, cart.Status).
+		Update("status", to)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &InvalidCartTransitionError{From: cart.Status, To: to}
+	}
+
+	change := models.CartStatusChange{
+		CartID: cart.ID,
+		From:   cart.Status,
+		To:     to,
+		Reason: reason,
+	}
+	if err := tx.Create(&change).Error; err != nil {
 		return err
 	}
 
+	if to == models.CartAbandoned || to == models.CartExpired || to == models.CartMerged {
+		if err := releaseCartReservations(tx, cart.ID); err != nil {
+			return err
+		}
+	}
+
+	cart.Status = to
 	return nil
 }
 
 // Scopes
 
-func WithProducts() func(db *gorm.DB) *gorm.DB {
+func WithItems() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Preload("Items", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id")
+			}).
+			Preload("Items.Product").
+			Preload("Items.Product.Category").
+			Preload("Items.Product.Prices").
+			Preload("Items.Product.Translations")
+	}
+}
+
+func WithCoupons() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Preload("Products")
+		return db.Preload("Coupons")
 	}
 }
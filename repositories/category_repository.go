@@ -1,6 +1,11 @@
 package repositories
 
-import "gorm.io/gorm"
+import (
+	"store_backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
 
 type CategoryRepository struct {
 	db *gorm.DB
@@ -9,3 +14,99 @@ type CategoryRepository struct {
 func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
+
+func (r CategoryRepository) GetAll() ([]models.Category, error) {
+	var categories []models.Category
+
+	err := r.db.Scopes(
+		WithTranslations(),
+		OrderBy("name", "asc"),
+	).Find(&categories).Error
+
+	return categories, err
+}
+
+func (r CategoryRepository) GetByID(id uint) (*models.Category, error) {
+	var category models.Category
+	if err := r.db.Scopes(WithTranslations()).First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r CategoryRepository) Create(category *models.Category) (*models.Category, error) {
+	if err := r.db.Create(category).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (r CategoryRepository) Update(category *models.Category) (*models.Category, error) {
+	if err := r.db.Save(category).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// SetTranslation sets the name of the category in a locale.
+func (r CategoryRepository) SetTranslation(categoryID uint, locale string, name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Category{}, categoryID).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "category_id"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name"}),
+		}).Create(&models.CategoryTranslation{CategoryID: categoryID, Locale: locale, Name: name}).Error
+	})
+}
+
+// DeleteTranslation removes the name of the category in a locale, the
+// default name is shown there again.
+func (r CategoryRepository) DeleteTranslation(categoryID uint, locale string) error {
+	res := r.db.Where("category_id = ? AND locale = ?", categoryID, locale).Delete(&models.CategoryTranslation{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes the category and detaches its products by setting their
+// CategoryID to NULL, so no product is left pointing at a deleted category.
+func (r CategoryRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Category{}, id).Error; err != nil {
+			return err
+		}
+
+		// Soft-deleted products are detached as well.
+		if err := tx.Unscoped().Model(&models.Product{}).
+			Scopes(ByCategory(id)).
+			Update("category_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.Category{}, id).Error
+	})
+}
+
+func (r CategoryRepository) GetProducts(categoryID uint) ([]models.Product, error) {
+	var products []models.Product
+
+	if _, err := r.GetByID(categoryID); err != nil {
+		return nil, err
+	}
+
+	err := r.db.Scopes(
+		ByCategory(categoryID),
+		WithPrices(),
+		WithTranslations(),
+		OrderBy("created_at", "desc"),
+	).Find(&products).Error
+
+	return products, err
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"store_backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponNotFound  = errors.New("coupon not found")
+	ErrCouponNotUsable = errors.New("coupon is not valid at this time")
+	ErrCouponNotInCart = errors.New("coupon is not applied to cart")
+)
+
+// CouponUsedUpError is returned at checkout when a coupon reached its usage
+// limit after it was applied to the cart.
+type CouponUsedUpError struct {
+	Code string
+}
+
+func (e *CouponUsedUpError) Error() string {
+	return fmt.Sprintf("coupon %q has reached its usage limit", e.Code)
+}
+
+type CouponRepository struct {
+	db *gorm.DB
+}
+
+func NewCouponRepository(db *gorm.DB) *CouponRepository {
+	return &CouponRepository{db: db}
+}
+
+func (r CouponRepository) GetAll() ([]models.Coupon, error) {
+	var coupons []models.Coupon
+
+	err := r.db.Scopes(
+		OrderBy("created_at", "desc"),
+	).Find(&coupons).Error
+
+	return coupons, err
+}
+
+func (r CouponRepository) GetByID(id uint) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.db.First(&coupon, id).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r CouponRepository) Create(coupon *models.Coupon) (*models.Coupon, error) {
+	if err := r.db.Create(coupon).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// Update saves the coupon definition. The usage count is left alone, it is
+// only changed by checkouts.
+func (r CouponRepository) Update(coupon *models.Coupon) (*models.Coupon, error) {
+	if err := r.db.Model(coupon).Select("*").Omit("usage_count", "created_at").Updates(coupon).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// Delete removes the coupon for good, so its code can be used again, and takes
+// it off every cart it was applied to. Orders keep their own copy of the
+// coupon.
+func (r CouponRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Coupon{}, id).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM cart_coupons WHERE coupon_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.OrderCoupon{}).Where("coupon_id = ?", id).Update("coupon_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.Coupon{}, id).Error
+	})
+}
+
+// findCouponByCode looks up a coupon by its normalized code.
+func findCouponByCode(tx *gorm.DB, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+
+	if err := tx.Where("code = ?", code).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+// useCoupon counts one more use of the coupon, failing if that would go over
+// its usage limit.
+func useCoupon(tx *gorm.DB, couponID uint, code string) error {
+	res := tx.Model(&models.Coupon{}).
+		Where("id = ? AND (usage_limit IS NULL OR usage_count < usage_limit)", couponID).
+		Update("usage_count", gorm.Expr("usage_count + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &CouponUsedUpError{Code: code}
+	}
+	return nil
+}
+
+// AddCoupon applies the coupon with the given code to the cart. Applying a
+// coupon that is already on the cart does nothing.
+func (r CartRepository) AddCoupon(cartID uint, code string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := findActiveCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		coupon, err := findCouponByCode(tx, code)
+		if err != nil {
+			return err
+		}
+
+		if !coupon.Usable(time.Now()) {
+			return ErrCouponNotUsable
+		}
+
+		return tx.Model(cart).Association("Coupons").Append(coupon)
+	})
+}
+
+func (r CartRepository) RemoveCoupon(cartID uint, code string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := findActiveCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		coupon, err := findCouponByCode(tx, code)
+		if errors.Is(err, ErrCouponNotFound) {
+			return ErrCouponNotInCart
+		} else if err != nil {
+			return err
+		}
+
+		res := tx.Exec("DELETE FROM cart_coupons WHERE cart_id = ? AND coupon_id = ?", cart.ID, coupon.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrCouponNotInCart
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"store_backend/models"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+func (r ExchangeRateRepository) GetAll() ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+
+	err := r.db.Scopes(
+		OrderBy("currency", "asc"),
+	).Find(&rates).Error
+
+	return rates, err
+}
+
+func (r ExchangeRateRepository) Get(currency string) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	if err := r.db.First(&rate, "currency = ?", currency).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// SetAll creates or replaces the rates of the given currencies. Currencies
+// that are not listed keep their rate.
+func (r ExchangeRateRepository) SetAll(rates map[string]decimal.Decimal) ([]models.ExchangeRate, error) {
+	return setExchangeRates(r.db, rates)
+}
+
+func (r ExchangeRateRepository) Delete(currency string) error {
+	res := r.db.Delete(&models.ExchangeRate{}, "currency = ?", currency)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func setExchangeRates(db *gorm.DB, rates map[string]decimal.Decimal) ([]models.ExchangeRate, error) {
+	rows := make([]models.ExchangeRate, 0, len(rates))
+	for currency, rate := range rates {
+		rows = append(rows, models.ExchangeRate{Currency: currency, Rate: rate})
+	}
+
+	if len(rows) == 0 {
+		return rows, nil
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&rows).Error
+
+	return rows, err
+}
@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"store_backend/models"
+	"store_backend/pricing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCartEmpty = errors.New("cart is empty")
+	// ErrShippingMethodRequired is returned when a cart that can be shipped
+	// is checked out without a shipping method.
+	ErrShippingMethodRequired = errors.New("shipping method required")
+	// ErrShippingMethodUnavailable is returned when the cart's shipping
+	// method does not deliver to its destination or cannot carry it.
+	ErrShippingMethodUnavailable = errors.New("shipping method unavailable")
+	// ErrPaymentMethodRequired is returned when a cart that has to be paid
+	// for is checked out without a payment method.
+	ErrPaymentMethodRequired = errors.New("payment method required")
+)
+
+type OrderRepository struct {
+	db       *gorm.DB
+	pricing  *pricing.Calculator
+	payments *PaymentRepository
+}
+
+func NewOrderRepository(db *gorm.DB, calculator *pricing.Calculator, payments *PaymentRepository) *OrderRepository {
+	return &OrderRepository{db: db, pricing: calculator, payments: payments}
+}
+
+func (r OrderRepository) GetAllByUser(userID uint) ([]models.Order, error) {
+	var orders []models.Order
+
+	err := r.db.Scopes(
+		WithLines(),
+		OwnedBy(userID),
+		OrderBy("created_at", "desc"),
+	).Find(&orders).Error
+
+	return orders, err
+}
+
+func (r OrderRepository) GetByID(id uint) (*models.Order, error) {
+	var order models.Order
+
+	if err := r.db.Scopes(
+		WithLines(),
+	).First(&order, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// Checkout is what the customer chose at checkout besides the contents of the
+// cart. The order is priced in Currency. PaymentMethod is the token of the
+// customer's payment method at the payment provider.
+type Checkout struct {
+	// UserID is the signed in user checking out, who the order and a guest
+	// cart are assigned to.
+	UserID          uint
+	Currency        pricing.Currency
+	ShippingAddress models.PostalAddress
+	BillingAddress  models.PostalAddress
+	PaymentMethod   string
+}
+
+// CreateFromCart turns the cart into an order, takes the ordered quantities
+// out of stock and marks the cart as checked out in a single transaction. An
+// active cart is locked first. Items whose product has been deleted are left
+// out. Coupon discounts are copied onto the order and count as a use of the
+// coupon. The order is priced in the checkout currency, and records its rate.
+// The cart is delivered to the shipping address, and a cart with shipping
+// methods on offer there must have one of them chosen.
+//
+// The order is committed awaiting payment, and only then is its total
+// authorized with the payment provider, so that no funds are held for an order
+// that was rolled back. If the payment is declined the attempt is kept, the
+// order is cancelled, the cart reopened and a PaymentError is returned.
+// Pending payments leave the order awaiting payment until the provider reports
+// back.
+func (r OrderRepository) CreateFromCart(cartID uint, checkout Checkout) (*models.Order, error) {
+	var order models.Order
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var cart models.Cart
+
+		if err := tx.Scopes(WithItems(), WithCoupons()).First(&cart, cartID).Error; err != nil {
+			return err
+		}
+
+		// A guest cart becomes the buyer's, so that it and the order are
+		// listed among theirs.
+		if cart.UserID == nil {
+			cart.UserID = &checkout.UserID
+			err := tx.Model(&models.Cart{}).Where("id = ?", cart.ID).Updates(map[string]interface{}{
+				"user_id": checkout.UserID, "token_hash": "",
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if cart.Status == models.CartActive {
+			if err := transitionCart(tx, &cart, models.CartLocked, "checkout started"); err != nil {
+				return err
+			}
+		}
+
+		cart.Country = checkout.ShippingAddress.Country
+		cart.Region = checkout.ShippingAddress.Region
+		cart.Postcode = checkout.ShippingAddress.Postcode
+
+		err := tx.Model(&models.Cart{}).Where("id = ?", cart.ID).Updates(map[string]interface{}{
+			"country": cart.Country, "region": cart.Region, "postcode": cart.Postcode,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		rules := pricing.Rules{Currency: checkout.Currency}
+
+		if rules.Promotions, err = activePromotions(tx); err != nil {
+			return err
+		}
+
+		country, region := r.pricing.Destination(&cart)
+		if rules.TaxRates, err = taxRatesFor(tx, country, region); err != nil {
+			return err
+		}
+
+		if rules.ShippingMethods, err = shippingMethodsFor(tx, country, cart.Postcode); err != nil {
+			return err
+		}
+
+		totals := r.pricing.CalculateWith(&cart, rules)
+
+		order = models.Order{
+			UserID:           cart.UserID,
+			CartID:           cart.ID,
+			Subtotal:         totals.Subtotal,
+			Discount:         totals.Discount,
+			Tax:              totals.Tax,
+			PricesIncludeTax: totals.PricesIncludeTax,
+			Country:          country,
+			Region:           region,
+			Postcode:         cart.Postcode,
+			Shipping:         totals.Shipping,
+			Total:            totals.Total,
+			Status:           models.OrderAwaitingPayment,
+			ShippingAddress:  checkout.ShippingAddress,
+			BillingAddress:   checkout.BillingAddress,
+			Currency:         totals.Currency,
+			ExchangeRate:     totals.ExchangeRate,
+		}
+
+		names := map[uint]string{}
+		for _, item := range cart.Items {
+			if item.Product != nil {
+				names[item.ProductID] = item.Product.Name
+			}
+		}
+
+		for _, line := range totals.Lines {
+			order.Lines = append(order.Lines, models.OrderLine{
+				ProductID:   &line.ProductID,
+				ProductName: names[line.ProductID],
+				UnitPrice:   line.UnitPrice,
+				Quantity:    line.Quantity,
+				LineTotal:   line.Total,
+			})
+		}
+
+		if len(order.Lines) == 0 {
+			return ErrCartEmpty
+		}
+
+		if method := totals.ShippingMethod; method != nil {
+			order.ShippingMethodID = &method.MethodID
+			order.ShippingMethod = method.Name
+		} else if cart.ShippingMethodID != nil {
+			return ErrShippingMethodUnavailable
+		} else if len(r.pricing.ShippingOptionsWith(&cart, rules)) > 0 {
+			return ErrShippingMethodRequired
+		}
+
+		for _, promotion := range totals.Promotions {
+			order.Promotions = append(order.Promotions, models.OrderPromotion{
+				PromotionID: &promotion.PromotionID,
+				Label:       promotion.Label,
+				Discount:    promotion.Discount,
+			})
+		}
+
+		for _, tax := range totals.Taxes {
+			order.Taxes = append(order.Taxes, models.OrderTax{
+				TaxRateID: &tax.TaxRateID,
+				Name:      tax.Name,
+				Rate:      tax.Rate,
+				Net:       tax.Net,
+				Amount:    tax.Amount,
+			})
+		}
+
+		for _, coupon := range totals.Coupons {
+			if !coupon.Applied {
+				continue
+			}
+
+			if err := useCoupon(tx, coupon.CouponID, coupon.Code); err != nil {
+				return err
+			}
+
+			order.Coupons = append(order.Coupons, models.OrderCoupon{
+				CouponID: &coupon.CouponID,
+				Code:     coupon.Code,
+				Type:     coupon.Type,
+				Value:    coupon.Value,
+				Discount: coupon.Discount,
+			})
+		}
+
+		if order.Total.IsPositive() && checkout.PaymentMethod == "" {
+			return ErrPaymentMethodRequired
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		// Reserved units already left the available quantity, only the part
+		// of a line whose reservation lapsed still has to be taken from it.
+		note := fmt.Sprintf("order %d", order.ID)
+		for _, item := range cart.Items {
+			if item.Product == nil {
+				continue
+			}
+
+			unreserved := item.Quantity - item.ReservedQuantity
+			if _, err := adjustStock(tx, item.ProductID, -item.Quantity, -unreserved, models.StockSale, note); err != nil {
+				return err
+			}
+		}
+
+		err = tx.Model(&models.CartItem{}).
+			Where("cart_id = ?", cart.ID).
+			Updates(map[string]interface{}{"reserved_quantity": 0, "reserved_until": nil}).Error
+		if err != nil {
+			return err
+		}
+
+		if !order.Total.IsPositive() {
+			if err := setOrderStatus(tx, &order.ID, models.OrderPaid); err != nil {
+				return err
+			}
+		}
+
+		return transitionCart(tx, &cart, models.CartCheckedOut, fmt.Sprintf("order %d created", order.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Total.IsPositive() {
+		payment, err := r.payments.authorize(&order, checkout.PaymentMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := r.payments.settle(payment); err != nil {
+			log.Printf("error capturing payment %d: %v", payment.ID, err)
+		}
+	}
+
+	return r.GetByID(order.ID)
+}
+
+// Scopes
+
+func WithLines() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Preload("Lines", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id")
+			}).
+			Preload("Coupons").
+			Preload("Promotions").
+			Preload("Taxes").
+			Preload("Payments", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id")
+			})
+	}
+}
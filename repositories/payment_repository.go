@@ -0,0 +1,388 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"store_backend/models"
+	"store_backend/payments"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var ErrRefundTooLarge = errors.New("refund exceeds the captured amount")
+
+// PaymentError is returned when checkout could not take the payment, because
+// it was declined or the provider could not be reached. Payment is the
+// attempt, which is kept.
+type PaymentError struct {
+	Payment *models.Payment
+}
+
+func (e *PaymentError) Error() string {
+	return fmt.Sprintf("payment %s: %s", e.Payment.Status, e.Payment.FailureReason)
+}
+
+type InvalidPaymentOperationError struct {
+	Operation string
+	Status    models.PaymentStatus
+}
+
+func (e *InvalidPaymentOperationError) Error() string {
+	return fmt.Sprintf("cannot %s a %s payment", e.Operation, e.Status)
+}
+
+type PaymentRepository struct {
+	db          *gorm.DB
+	provider    payments.Provider
+	autoCapture bool
+}
+
+func NewPaymentRepository(db *gorm.DB, provider payments.Provider, autoCapture bool) *PaymentRepository {
+	return &PaymentRepository{db: db, provider: provider, autoCapture: autoCapture}
+}
+
+// Provider returns the provider payments are taken through.
+func (r PaymentRepository) Provider() payments.Provider {
+	return r.provider
+}
+
+func (r PaymentRepository) GetAll() ([]models.Payment, error) {
+	var list []models.Payment
+
+	err := r.db.Scopes(
+		OrderBy("id", "desc"),
+	).Find(&list).Error
+
+	return list, err
+}
+
+// GetByCart returns every attempt to pay for the cart, oldest first.
+func (r PaymentRepository) GetByCart(cartID uint) ([]models.Payment, error) {
+	var list []models.Payment
+
+	err := r.db.Where("cart_id = ?", cartID).Order("id").Find(&list).Error
+
+	return list, err
+}
+
+func (r PaymentRepository) GetByID(id uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.First(&payment, id).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Capture collects an authorized payment, which marks its order as paid.
+func (r PaymentRepository) Capture(id uint) (*models.Payment, error) {
+	payment, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentAuthorized {
+		return nil, &InvalidPaymentOperationError{Operation: "capture", Status: payment.Status}
+	}
+
+	if _, err := r.provider.Capture(context.Background(), *payment.ProviderRef, payment.Amount); err != nil {
+		return nil, err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := updatePayment(tx, payment, models.PaymentAuthorized, models.PaymentCaptured); err != nil {
+			return err
+		}
+		return setOrderStatus(tx, payment.OrderID, models.OrderPaid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Refund gives back part of a captured payment, or all of what is left of it
+// if amount is zero. The order is marked as refunded once nothing is left.
+func (r PaymentRepository) Refund(id uint, amount decimal.Decimal) (*models.Payment, error) {
+	payment, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentCaptured {
+		return nil, &InvalidPaymentOperationError{Operation: "refund", Status: payment.Status}
+	}
+
+	left := payment.Amount.Sub(payment.RefundedAmount)
+	if amount.IsZero() {
+		amount = left
+	}
+	if amount.GreaterThan(left) {
+		return nil, ErrRefundTooLarge
+	}
+
+	if _, err := r.provider.Refund(context.Background(), *payment.ProviderRef, amount); err != nil {
+		return nil, err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		refunded := payment.RefundedAmount.Add(amount)
+
+		res := tx.Model(payment).
+			Where("status = ? AND refunded_amount = ?", models.PaymentCaptured, payment.RefundedAmount).
+			Update("refunded_amount", refunded)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return &InvalidPaymentOperationError{Operation: "refund", Status: payment.Status}
+		}
+
+		if refunded.LessThan(payment.Amount) {
+			return nil
+		}
+
+		if err := updatePayment(tx, payment, models.PaymentCaptured, models.PaymentRefunded); err != nil {
+			return err
+		}
+		return setOrderStatus(tx, payment.OrderID, models.OrderRefunded)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(id)
+}
+
+// Void releases a payment that was not captured and cancels its order.
+func (r PaymentRepository) Void(id uint) (*models.Payment, error) {
+	payment, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	from := payment.Status
+	if from != models.PaymentAuthorized && from != models.PaymentPending {
+		return nil, &InvalidPaymentOperationError{Operation: "void", Status: from}
+	}
+
+	if _, err := r.provider.Void(context.Background(), *payment.ProviderRef); err != nil {
+		return nil, err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := updatePayment(tx, payment, from, models.PaymentVoided); err != nil {
+			return err
+		}
+		return cancelOrder(tx, payment.OrderID, "payment voided")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// HandleEvent applies the outcome of a pending payment reported by the
+// provider. Events for payments that are no longer pending were handled
+// before and are ignored, as providers deliver them more than once.
+func (r PaymentRepository) HandleEvent(event payments.Event) (*models.Payment, error) {
+	var payment models.Payment
+
+	err := r.db.Where("provider = ? AND provider_ref = ?", r.provider.Name(), event.ProviderRef).
+		First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != models.PaymentPending {
+		return &payment, nil
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		switch event.Status {
+		case payments.StatusAuthorized:
+			return updatePayment(tx, &payment, models.PaymentPending, models.PaymentAuthorized)
+		case payments.StatusDeclined:
+			payment.FailureReason = event.Reason
+			if err := tx.Model(&payment).Update("failure_reason", event.Reason).Error; err != nil {
+				return err
+			}
+			if err := updatePayment(tx, &payment, models.PaymentPending, models.PaymentDeclined); err != nil {
+				return err
+			}
+			return failCheckout(tx, &payment, "payment declined")
+		}
+		return payments.ErrInvalidEvent
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.settle(&payment)
+}
+
+// settle captures an authorized payment right away when auto capture is on.
+// A failed capture leaves the payment authorized, to be captured later.
+func (r PaymentRepository) settle(payment *models.Payment) (*models.Payment, error) {
+	if !r.autoCapture || payment.Status != models.PaymentAuthorized {
+		return payment, nil
+	}
+
+	captured, err := r.Capture(payment.ID)
+	if err != nil {
+		return payment, err
+	}
+	return captured, nil
+}
+
+// authorize asks the provider to authorize the total of a committed order and
+// records the payment. A declined or failed payment is kept as well, cancels
+// the order and reopens its cart, and is returned as a PaymentError.
+func (r PaymentRepository) authorize(order *models.Order, method string) (*models.Payment, error) {
+	payment := &models.Payment{
+		CartID:   order.CartID,
+		OrderID:  &order.ID,
+		Provider: r.provider.Name(),
+		Amount:   order.Total,
+		Currency: order.Currency,
+	}
+
+	result, err := r.provider.Authorize(context.Background(), payments.AuthorizeRequest{
+		Reference: fmt.Sprintf("order-%d", order.ID),
+		Amount:    order.Total,
+		Currency:  order.Currency,
+		Method:    method,
+	})
+	if err != nil {
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = err.Error()
+		return nil, r.fail(payment)
+	}
+
+	payment.ProviderRef = &result.ProviderRef
+	payment.ActionURL = result.ActionURL
+
+	switch result.Status {
+	case payments.StatusPending:
+		payment.Status = models.PaymentPending
+	case payments.StatusAuthorized:
+		payment.Status = models.PaymentAuthorized
+	default:
+		payment.Status = models.PaymentDeclined
+		payment.FailureReason = result.Reason
+		return nil, r.fail(payment)
+	}
+
+	if err := r.db.Create(payment).Error; err != nil {
+		// Funds must not stay held for a payment nobody knows about.
+		if _, voidErr := r.provider.Void(context.Background(), result.ProviderRef); voidErr != nil {
+			log.Printf("error voiding unrecorded payment %s: %v", result.ProviderRef, voidErr)
+		}
+		if cancelErr := r.db.Transaction(func(tx *gorm.DB) error {
+			return failCheckout(tx, payment, "payment not recorded")
+		}); cancelErr != nil {
+			log.Printf("error cancelling order %d: %v", order.ID, cancelErr)
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// fail records a payment that did not go through, cancels its order and
+// reopens its cart. It returns the PaymentError to report.
+func (r PaymentRepository) fail(payment *models.Payment) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		return failCheckout(tx, payment, fmt.Sprintf("payment %s", payment.Status))
+	})
+	if err != nil {
+		return err
+	}
+	return &PaymentError{Payment: payment}
+}
+
+// updatePayment moves the payment from one status to another, failing if
+// something else changed its status in the meantime.
+func updatePayment(tx *gorm.DB, payment *models.Payment, from models.PaymentStatus, to models.PaymentStatus) error {
+	res := tx.Model(payment).Where("status = ?", from).Update("status", to)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &InvalidPaymentOperationError{Operation: string(to), Status: payment.Status}
+	}
+	payment.Status = to
+	return nil
+}
+
+func setOrderStatus(tx *gorm.DB, orderID *uint, status models.OrderStatus) error {
+	if orderID == nil {
+		return nil
+	}
+	return tx.Model(&models.Order{}).Where("id = ?", *orderID).Update("status", status).Error
+}
+
+// failCheckout cancels the order of a payment that did not go through and
+// reopens the cart it was created from, so that the customer can try again.
+func failCheckout(tx *gorm.DB, payment *models.Payment, reason string) error {
+	if err := cancelOrder(tx, payment.OrderID, reason); err != nil {
+		return err
+	}
+
+	var cart models.Cart
+	if err := tx.First(&cart, payment.CartID).Error; err != nil {
+		return err
+	}
+	if cart.Status != models.CartCheckedOut {
+		return nil
+	}
+	return transitionCart(tx, &cart, models.CartActive, reason)
+}
+
+// cancelOrder cancels an unpaid order: its stock is put back and its coupons
+// can be used again. Products deleted since are skipped.
+func cancelOrder(tx *gorm.DB, orderID *uint, reason string) error {
+	if orderID == nil {
+		return nil
+	}
+
+	var order models.Order
+	if err := tx.Scopes(WithLines()).First(&order, *orderID).Error; err != nil {
+		return err
+	}
+	if order.Status == models.OrderCancelled {
+		return nil
+	}
+
+	note := fmt.Sprintf("order %d %s", order.ID, reason)
+	for _, line := range order.Lines {
+		if line.ProductID == nil {
+			continue
+		}
+
+		_, err := adjustStock(tx, *line.ProductID, line.Quantity, line.Quantity, models.StockCancelled, note)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	for _, coupon := range order.Coupons {
+		if coupon.CouponID == nil {
+			continue
+		}
+
+		err := tx.Model(&models.Coupon{}).
+			Where("id = ? AND usage_count > 0", *coupon.CouponID).
+			Update("usage_count", gorm.Expr("usage_count - 1")).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return setOrderStatus(tx, &order.ID, models.OrderCancelled)
+}
@@ -1,9 +1,12 @@
 package repositories
 
 import (
+	"fmt"
 	"store_backend/models"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository struct {
@@ -14,12 +17,36 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
+type InsufficientStockError struct {
+	ProductID uint
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d: requested %d, available %d",
+		e.ProductID, e.Requested, e.Available)
+}
+
 type GetAllProductsOptions struct {
 	Page       int
 	PageSize   int
 	CategoryID uint
 	MinPrice   float64
 	MaxPrice   float64
+	InStock    bool
+	SortBy     string
+	SortDesc   bool
+}
+
+// ProductSortColumns maps the sort keys accepted by the API to product columns.
+// Anything outside this list must never reach OrderBy.
+var ProductSortColumns = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"price":     "price",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
 }
 
 func DefaultGetAllProductsOptions() *GetAllProductsOptions {
@@ -29,49 +56,135 @@ func DefaultGetAllProductsOptions() *GetAllProductsOptions {
 		CategoryID: 0,
 		MinPrice:   0,
 		MaxPrice:   0,
+		SortBy:     "createdAt",
+		SortDesc:   true,
 	}
 }
 
-func (r ProductRepository) GetAll(opts *GetAllProductsOptions) ([]models.Product, error) {
+// GetAll returns a single page of products matching opts together with the
+// total number of matching products across all pages.
+func (r ProductRepository) GetAll(opts *GetAllProductsOptions) ([]models.Product, int64, error) {
 	var products []models.Product
+	var total int64
 
 	if opts == nil {
 		opts = DefaultGetAllProductsOptions()
 	}
 
-	err := r.db.Scopes(
-		WithCategory(),
-		Paginate(opts.Page, opts.PageSize),
+	sortColumn, ok := ProductSortColumns[opts.SortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported sort column: %q", opts.SortBy)
+	}
+
+	sortDirection := "asc"
+	if opts.SortDesc {
+		sortDirection = "desc"
+	}
+
+	filters := []func(db *gorm.DB) *gorm.DB{
 		ByCategory(opts.CategoryID),
 		PriceRange(opts.MinPrice, opts.MaxPrice),
-		OrderBy("created_at", "desc"),
+	}
+
+	if opts.InStock {
+		filters = append(filters, InStock())
+	}
+
+	if err := r.db.Model(&models.Product{}).Scopes(filters...).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Scopes(filters...).Scopes(
+		WithCategory(),
+		WithPrices(),
+		WithTranslations(),
+		Paginate(opts.Page, opts.PageSize),
+		OrderBy(sortColumn, sortDirection),
 	).Find(&products).Error
 
-	return products, err
+	return products, total, err
 }
 
 func (r ProductRepository) GetByID(id uint) (*models.Product, error) {
 	var product models.Product
-	if err := r.db.First(&product, id).Error; err != nil {
+	if err := r.db.Scopes(WithPrices(), WithTranslations()).First(&product, id).Error; err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
 func (r ProductRepository) Create(product *models.Product) (*models.Product, error) {
+	product.AvailableQuantity = product.StockQuantity
+
 	if err := r.db.Create(product).Error; err != nil {
 		return nil, err
 	}
 	return product, nil
 }
 
+// Update saves the product. Stock levels are left alone, they only change
+// through AdjustStock, carts and checkout.
 func (r ProductRepository) Update(product *models.Product) (*models.Product, error) {
-	if err := r.db.Save(product).Error; err != nil {
+	if err := r.db.Omit("stock_quantity", "available_quantity").Save(product).Error; err != nil {
 		return nil, err
 	}
 	return product, nil
 }
 
+// SetPrice sets the fixed price of the product in a currency.
+func (r ProductRepository) SetPrice(productID uint, currency string, price decimal.Decimal) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Product{}, productID).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+			DoUpdates: clause.AssignmentColumns([]string{"price"}),
+		}).Create(&models.ProductPrice{ProductID: productID, Currency: currency, Price: price}).Error
+	})
+}
+
+// DeletePrice removes the fixed price of the product in a currency, its price
+// is converted from the base price again.
+func (r ProductRepository) DeletePrice(productID uint, currency string) error {
+	res := r.db.Where("product_id = ? AND currency = ?", productID, currency).Delete(&models.ProductPrice{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetTranslation sets the name of the product in a locale.
+func (r ProductRepository) SetTranslation(productID uint, locale string, name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Product{}, productID).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name"}),
+		}).Create(&models.ProductTranslation{ProductID: productID, Locale: locale, Name: name}).Error
+	})
+}
+
+// DeleteTranslation removes the name of the product in a locale, the default
+// name is shown there again.
+func (r ProductRepository) DeleteTranslation(productID uint, locale string) error {
+	res := r.db.Where("product_id = ? AND locale = ?", productID, locale).Delete(&models.ProductTranslation{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (r ProductRepository) Delete(id uint) error {
 	if err := r.db.Delete(&models.Product{}, id).Error; err != nil {
 		return err
@@ -79,6 +192,78 @@ func (r ProductRepository) Delete(id uint) error {
 	return nil
 }
 
+// AdjustStock changes the product's stock by delta and records the movement.
+// Units reserved by carts cannot be adjusted away.
+func (r ProductRepository) AdjustStock(productID uint, delta int, reason models.StockReason, note string) (*models.StockMovement, error) {
+	var movement *models.StockMovement
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = adjustStock(tx, productID, delta, delta, reason, note)
+		return err
+	})
+
+	return movement, err
+}
+
+func (r ProductRepository) GetStockMovements(productID uint) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+
+	if _, err := r.GetByID(productID); err != nil {
+		return nil, err
+	}
+
+	err := r.db.Where("product_id = ?", productID).Order("id desc").Find(&movements).Error
+
+	return movements, err
+}
+
+// adjustStock changes the stock on hand by delta and the available quantity by
+// availableDelta. The two differ only when units that were already reserved
+// leave the warehouse.
+func adjustStock(tx *gorm.DB, productID uint, delta int, availableDelta int, reason models.StockReason, note string) (*models.StockMovement, error) {
+	var product models.Product
+
+	if err := tx.First(&product, productID).Error; err != nil {
+		return nil, err
+	}
+
+	res := tx.Model(&models.Product{}).
+		Where("id = ? AND stock_quantity + ? >= 0 AND available_quantity + ? >= 0",
+			productID, delta, availableDelta).
+		Updates(map[string]interface{}{
+			"stock_quantity":     gorm.Expr("stock_quantity + ?", delta),
+			"available_quantity": gorm.Expr("available_quantity + ?", availableDelta),
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, &InsufficientStockError{
+			ProductID: productID,
+			Requested: -availableDelta,
+			Available: product.AvailableQuantity,
+		}
+	}
+
+	if err := tx.Select("stock_quantity").First(&product, productID).Error; err != nil {
+		return nil, err
+	}
+
+	movement := models.StockMovement{
+		ProductID: productID,
+		Delta:     delta,
+		Quantity:  product.StockQuantity,
+		Reason:    reason,
+		Note:      note,
+	}
+	if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+
+	return &movement, nil
+}
+
 // Scopes
 
 func WithCategory() func(db *gorm.DB) *gorm.DB {
@@ -87,6 +272,22 @@ func WithCategory() func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+func WithPrices() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Prices", func(db *gorm.DB) *gorm.DB {
+			return db.Order("currency")
+		})
+	}
+}
+
+func WithTranslations() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Translations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("locale")
+		})
+	}
+}
+
 func ByCategory(categoryID uint) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		if categoryID > 0 {
@@ -109,8 +310,7 @@ func PriceRange(min, max float64) func(db *gorm.DB) *gorm.DB {
 }
 
 func InStock() func(db *gorm.DB) *gorm.DB {
-	// not implemented in DB
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Where("stock_quantity > 0")
+		return db.Where("available_quantity > 0")
 	}
 }
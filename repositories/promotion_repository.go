@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"store_backend/models"
+
+	"gorm.io/gorm"
+)
+
+type PromotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) *PromotionRepository {
+	return &PromotionRepository{db: db}
+}
+
+func (r PromotionRepository) GetAll() ([]models.Promotion, error) {
+	var promotions []models.Promotion
+
+	err := r.db.Scopes(
+		OrderBy("id", "asc"),
+	).Find(&promotions).Error
+
+	return promotions, err
+}
+
+// GetActive returns the promotions that are applied to carts, in the order
+// they are evaluated.
+func (r PromotionRepository) GetActive() ([]models.Promotion, error) {
+	return activePromotions(r.db)
+}
+
+func (r PromotionRepository) GetByID(id uint) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := r.db.First(&promotion, id).Error; err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (r PromotionRepository) Create(promotion *models.Promotion) (*models.Promotion, error) {
+	if err := r.db.Create(promotion).Error; err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (r PromotionRepository) Update(promotion *models.Promotion) (*models.Promotion, error) {
+	if err := r.db.Save(promotion).Error; err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (r PromotionRepository) Delete(id uint) error {
+	res := r.db.Delete(&models.Promotion{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func activePromotions(tx *gorm.DB) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+
+	err := tx.Where("active = ?", true).Order("id").Find(&promotions).Error
+
+	return promotions, err
+}
@@ -1,17 +1,50 @@
 package repositories
 
-import "gorm.io/gorm"
+import (
+	"store_backend/environment"
+	"store_backend/payments"
+	"store_backend/pricing"
+
+	"gorm.io/gorm"
+)
 
 type Repositories struct {
 	Products   *ProductRepository
 	Categories *CategoryRepository
 	Carts      *CartRepository
+	Orders     *OrderRepository
+	Users      *UserRepository
+	Addresses  *AddressRepository
+	APIKeys    *APIKeyRepository
+	Coupons    *CouponRepository
+	Promotions *PromotionRepository
+	Taxes      *TaxRepository
+	Shipping   *ShippingRepository
+	Payments   *PaymentRepository
+	// ExchangeRates convert prices from the base currency.
+	ExchangeRates *ExchangeRateRepository
 }
 
-func Initialize(db *gorm.DB) Repositories {
+func Initialize(db *gorm.DB, env environment.Environment) Repositories {
+	promotions := NewPromotionRepository(db)
+	taxes := NewTaxRepository(db)
+	shipping := NewShippingRepository(db)
+	payments := NewPaymentRepository(db, payments.NewProvider(env), env.PaymentAutoCapture)
+
 	return Repositories{
 		Products:   NewProductRepository(db),
 		Categories: NewCategoryRepository(db),
-		Carts:      NewCartRepository(db),
+		Carts:      NewCartRepository(db, env.ReservationTTL),
+		Orders:     NewOrderRepository(db, pricing.NewCalculator(env, promotions, taxes, shipping), payments),
+		Users:      NewUserRepository(db),
+		Addresses:  NewAddressRepository(db),
+		APIKeys:    NewAPIKeyRepository(db),
+		Coupons:    NewCouponRepository(db),
+		Promotions: promotions,
+		Taxes:      taxes,
+		Shipping:   shipping,
+		Payments:   payments,
+
+		ExchangeRates: NewExchangeRateRepository(db),
 	}
 }
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"store_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// reserveStock takes quantity units of the product out of its available
+// quantity. A negative quantity gives units back.
+func reserveStock(tx *gorm.DB, productID uint, quantity int) error {
+	if quantity == 0 {
+		return nil
+	}
+
+	res := tx.Model(&models.Product{}).
+		Where("id = ? AND available_quantity >= ?", productID, quantity).
+		Update("available_quantity", gorm.Expr("available_quantity - ?", quantity))
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 && quantity > 0 {
+		var product models.Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return err
+		}
+
+		return &InsufficientStockError{
+			ProductID: productID,
+			Requested: quantity,
+			Available: product.AvailableQuantity,
+		}
+	}
+
+	return nil
+}
+
+// releaseReservation returns the stock held by the cart item without changing
+// its quantity.
+func releaseReservation(tx *gorm.DB, item *models.CartItem) error {
+	if err := reserveStock(tx, item.ProductID, -item.ReservedQuantity); err != nil {
+		return err
+	}
+
+	item.ReservedQuantity = 0
+	item.ReservedUntil = nil
+
+	return tx.Model(item).Select("reserved_quantity", "reserved_until").Updates(item).Error
+}
+
+func releaseCartReservations(tx *gorm.DB, cartID uint) error {
+	var items []models.CartItem
+
+	if err := tx.Where("cart_id = ? AND reserved_quantity > 0", cartID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	for i := range items {
+		if err := releaseReservation(tx, &items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
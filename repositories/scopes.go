@@ -1,7 +1,10 @@
 package repositories
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Pagination adds limit and offset to queries
@@ -12,10 +15,14 @@ func Paginate(page, pageSize int) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
-// OrderBy adds sorting to queries
+// OrderBy adds sorting to queries. The column is quoted as an identifier and
+// any direction other than "desc" sorts ascending.
 func OrderBy(column string, direction string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Order(column + " " + direction)
+		return db.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: column},
+			Desc:   strings.EqualFold(direction, "desc"),
+		})
 	}
 }
 
@@ -28,3 +35,10 @@ func Search(column, query string) func(db *gorm.DB) *gorm.DB {
 		return db
 	}
 }
+
+// OwnedBy limits queries to rows belonging to the user
+func OwnedBy(userID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", userID)
+	}
+}
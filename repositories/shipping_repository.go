@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"store_backend/models"
+
+	"gorm.io/gorm"
+)
+
+type ShippingRepository struct {
+	db *gorm.DB
+}
+
+func NewShippingRepository(db *gorm.DB) *ShippingRepository {
+	return &ShippingRepository{db: db}
+}
+
+// GetZones returns every zone with all of its methods.
+func (r ShippingRepository) GetZones() ([]models.ShippingZone, error) {
+	var zones []models.ShippingZone
+
+	err := r.db.Scopes(
+		WithShippingMethods(false),
+		OrderBy("id", "asc"),
+	).Find(&zones).Error
+
+	return zones, err
+}
+
+func (r ShippingRepository) GetZoneByID(id uint) (*models.ShippingZone, error) {
+	var zone models.ShippingZone
+	if err := r.db.Scopes(WithShippingMethods(false)).First(&zone, id).Error; err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r ShippingRepository) CreateZone(zone *models.ShippingZone) (*models.ShippingZone, error) {
+	if err := r.db.Omit("Methods").Create(zone).Error; err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
+
+func (r ShippingRepository) UpdateZone(zone *models.ShippingZone) (*models.ShippingZone, error) {
+	if err := r.db.Omit("Methods").Save(zone).Error; err != nil {
+		return nil, err
+	}
+	return r.GetZoneByID(zone.ID)
+}
+
+// DeleteZone removes the zone with its methods. Carts that chose one of the
+// methods are left without one.
+func (r ShippingRepository) DeleteZone(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.ShippingZone{}, id).Error; err != nil {
+			return err
+		}
+
+		methods := tx.Model(&models.ShippingMethod{}).Select("id").Where("zone_id = ?", id)
+		err := tx.Model(&models.Cart{}).
+			Where("shipping_method_id IN (?)", methods).
+			Update("shipping_method_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("zone_id = ?", id).Delete(&models.ShippingMethod{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.ShippingZone{}, id).Error
+	})
+}
+
+// GetMethods returns the active methods that deliver to the postcode in the
+// country.
+func (r ShippingRepository) GetMethods(country string, postcode string) ([]models.ShippingMethod, error) {
+	return shippingMethodsFor(r.db, country, postcode)
+}
+
+func (r ShippingRepository) GetMethodByID(id uint) (*models.ShippingMethod, error) {
+	var method models.ShippingMethod
+	if err := r.db.First(&method, id).Error; err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+func (r ShippingRepository) CreateMethod(method *models.ShippingMethod) (*models.ShippingMethod, error) {
+	if err := r.db.Create(method).Error; err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+func (r ShippingRepository) UpdateMethod(method *models.ShippingMethod) (*models.ShippingMethod, error) {
+	if err := r.db.Save(method).Error; err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+// DeleteMethod removes the method. Carts that chose it are left without one.
+func (r ShippingRepository) DeleteMethod(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.ShippingMethod{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&models.Cart{}).
+			Where("shipping_method_id = ?", id).
+			Update("shipping_method_id", nil).Error
+	})
+}
+
+// shippingMethodsFor returns the active methods of the most specific zones
+// covering the destination. Methods of less specific zones are not offered,
+// so a zone for some postcodes replaces the zone for the rest of the country.
+func shippingMethodsFor(tx *gorm.DB, country string, postcode string) ([]models.ShippingMethod, error) {
+	var zones []models.ShippingZone
+
+	if err := tx.Scopes(WithShippingMethods(true)).Order("id").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+
+	best := -1
+	for _, zone := range zones {
+		if zone.Matches(country, postcode) {
+			best = max(best, zone.Specificity())
+		}
+	}
+
+	methods := []models.ShippingMethod{}
+	for _, zone := range zones {
+		if zone.Matches(country, postcode) && zone.Specificity() == best {
+			methods = append(methods, zone.Methods...)
+		}
+	}
+
+	return methods, nil
+}
+
+// Scopes
+
+// WithShippingMethods preloads the methods of zones, only the active ones if
+// activeOnly is set.
+func WithShippingMethods(activeOnly bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Methods", func(db *gorm.DB) *gorm.DB {
+			if activeOnly {
+				db = db.Where("active = ?", true)
+			}
+			return db.Order("id")
+		})
+	}
+}
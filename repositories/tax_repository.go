@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"store_backend/models"
+
+	"gorm.io/gorm"
+)
+
+type TaxRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxRepository(db *gorm.DB) *TaxRepository {
+	return &TaxRepository{db: db}
+}
+
+func (r TaxRepository) GetClasses() ([]models.TaxClass, error) {
+	var classes []models.TaxClass
+
+	err := r.db.Scopes(
+		OrderBy("name", "asc"),
+	).Find(&classes).Error
+
+	return classes, err
+}
+
+func (r TaxRepository) GetClassByID(id uint) (*models.TaxClass, error) {
+	var class models.TaxClass
+	if err := r.db.First(&class, id).Error; err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+func (r TaxRepository) CreateClass(class *models.TaxClass) (*models.TaxClass, error) {
+	if err := r.db.Create(class).Error; err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+func (r TaxRepository) UpdateClass(class *models.TaxClass) (*models.TaxClass, error) {
+	if err := r.db.Save(class).Error; err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+// DeleteClass removes the tax class with its rates. Products and categories
+// in the class are left without one.
+func (r TaxRepository) DeleteClass(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.TaxClass{}, id).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&models.Product{}, &models.Category{}} {
+			if err := tx.Model(model).Where("tax_class_id = ?", id).Update("tax_class_id", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Where("tax_class_id = ?", id).Delete(&models.TaxRate{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.TaxClass{}, id).Error
+	})
+}
+
+func (r TaxRepository) GetAllRates() ([]models.TaxRate, error) {
+	var rates []models.TaxRate
+
+	err := r.db.Order("country, region, tax_class_id").Find(&rates).Error
+
+	return rates, err
+}
+
+// GetRates returns the rates that apply in the region of the country: the
+// rates for the whole country and those for the region itself.
+func (r TaxRepository) GetRates(country string, region string) ([]models.TaxRate, error) {
+	return taxRatesFor(r.db, country, region)
+}
+
+func (r TaxRepository) GetRateByID(id uint) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	if err := r.db.First(&rate, id).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r TaxRepository) CreateRate(rate *models.TaxRate) (*models.TaxRate, error) {
+	if err := r.db.Create(rate).Error; err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (r TaxRepository) UpdateRate(rate *models.TaxRate) (*models.TaxRate, error) {
+	if err := r.db.Save(rate).Error; err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// DeleteRate removes the rate for good, so a new rate can be created for the
+// same location.
+func (r TaxRepository) DeleteRate(id uint) error {
+	res := r.db.Unscoped().Delete(&models.TaxRate{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func taxRatesFor(tx *gorm.DB, country string, region string) ([]models.TaxRate, error) {
+	var rates []models.TaxRate
+
+	err := tx.Where("country = ? AND (region = '' OR region = ?)", country, region).
+		Order("id").
+		Find(&rates).Error
+
+	return rates, err
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"store_backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r UserRepository) GetAll() ([]models.User, error) {
+	var users []models.User
+
+	err := r.db.Scopes(
+		OrderBy("id", "asc"),
+	).Find(&users).Error
+
+	return users, err
+}
+
+func (r UserRepository) GetByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r UserRepository) GetByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r UserRepository) Create(user *models.User) (*models.User, error) {
+	if err := r.db.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r UserRepository) UpdateRole(id uint, role models.Role) (*models.User, error) {
+	user, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Model(user).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r UserRepository) CreateSession(userID uint, tokenHash string, expiresAt time.Time) (*models.Session, error) {
+	session := models.Session{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := r.db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSession replaces the unexpired session with the given refresh token
+// hash by a new one for the same user, so every refresh token works only once.
+func (r UserRepository) RotateSession(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	var session models.Session
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var old models.Session
+
+		err := tx.Preload("User").
+			Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).
+			First(&old).Error
+		if err != nil {
+			return err
+		}
+
+		if old.User == nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Delete(&old).Error; err != nil {
+			return err
+		}
+
+		session = models.Session{
+			UserID:    old.UserID,
+			User:      old.User,
+			TokenHash: newTokenHash,
+			ExpiresAt: expiresAt,
+		}
+		return tx.Omit("User").Create(&session).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r UserRepository) DeleteSession(tokenHash string) error {
+	return r.db.Where("token_hash = ?", tokenHash).Delete(&models.Session{}).Error
+}
@@ -3,60 +3,83 @@ package server
 import (
 	"fmt"
 	"log"
+	"store_backend/auth"
 	"store_backend/environment"
 	"store_backend/handlers"
+	"store_backend/i18n"
+	"store_backend/openapi"
+	"store_backend/problem"
+	"store_backend/repositories"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	slogecho "github.com/samber/slog-echo"
+	"github.com/shopspring/decimal"
 )
 
 type Server struct {
-	echo *echo.Echo
+	echo  *echo.Echo
+	usage *usage
+	env   environment.Environment
 }
 
-func Initialize(handlers []handlers.Handler, env environment.Environment) Server {
+func Initialize(versions []handlers.Version, repos repositories.Repositories, env environment.Environment) Server {
 	e := echo.New()
 
 	e.HideBanner = true
-	e.Validator = &customValidator{
-		validator: validator.New(validator.WithRequiredStructEnabled()),
-	}
+	e.Validator = newCustomValidator()
+	e.HTTPErrorHandler = problem.ErrorHandler(log.Printf)
+
+	configureMiddleware(e, repos, env)
 
-	configureMiddleware(e, env)
+	spec := openapi.New(openapi.Info{Title: "Store API", Version: "1.0.0"})
+	usage := newUsage()
 
-	for _, handler := range handlers {
-		if err := handler.RegisterRoutes(e); err != nil {
+	for _, version := range versions {
+		if err := mountVersion(e, spec, version, usage); err != nil {
 			panic(err)
 		}
 	}
 
-	return Server{echo: e}
+	if err := spec.RegisterRoutes(e); err != nil {
+		panic(err)
+	}
+	// Every route must be documented, so the document cannot fall behind
+	// the routes.
+	if err := spec.Build(e.Routes()); err != nil {
+		panic(err)
+	}
+
+	return Server{echo: e, usage: usage, env: env}
 }
 
 func (s Server) Start() {
 	log.Printf("Available routes:\n%s", printRoutes(s.echo.Routes()))
 
+	s.usage.start(s.env.Logger, s.env.UsageLogInterval)
+
 	s.echo.Logger.Fatal(s.echo.Start(":1323"))
 }
 
-func configureMiddleware(e *echo.Echo, env environment.Environment) {
+func configureMiddleware(e *echo.Echo, repos repositories.Repositories, env environment.Environment) {
 	slogEcho := slogecho.New(env.Logger)
 
 	e.Pre(middleware.RemoveTrailingSlash())
 
 	e.Use(slogEcho)
+	e.Use(i18n.Negotiate(env.DefaultLocale))
 	e.Use(middleware.Secure())
 	e.Use(middleware.Recover())
+	e.Use(auth.APIKeyAuth(repos.APIKeys))
 
 	frontendURL := "http://192.168.117.3:3000" // env.FRONTEND_URL
 
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:5173", frontendURL},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "*"},
+		AllowHeaders:     []string{"Authorization", auth.APIKeyHeader, "*"},
 		AllowCredentials: true,
 	}))
 }
@@ -77,6 +100,36 @@ type customValidator struct {
 	validator *validator.Validate
 }
 
+func newCustomValidator() *customValidator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(problem.FieldName)
+
+	// permission accepts the names of auth.Permissions, e.g. for API key scopes
+	if err := v.RegisterValidation("permission", func(fl validator.FieldLevel) bool {
+		return auth.ValidPermission(fl.Field().String())
+	}); err != nil {
+		panic(err)
+	}
+
+	// positive and nonnegative check decimal amounts, e.g. prices
+	if err := v.RegisterValidation("positive", func(fl validator.FieldLevel) bool {
+		d, ok := fl.Field().Interface().(decimal.Decimal)
+		return ok && d.IsPositive()
+	}); err != nil {
+		panic(err)
+	}
+
+	if err := v.RegisterValidation("nonnegative", func(fl validator.FieldLevel) bool {
+		d, ok := fl.Field().Interface().(decimal.Decimal)
+		return ok && !d.IsNegative()
+	}); err != nil {
+		panic(err)
+	}
+
+	return &customValidator{validator: v}
+}
+
+// Validate reports the fields of i that failed validation as a problem.
 func (cv *customValidator) Validate(i interface{}) error {
-	return cv.validator.Struct(i)
+	return problem.FromValidator(i, cv.validator.Struct(i))
 }
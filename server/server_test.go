@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"store_backend/environment"
+	"store_backend/handlers"
+	"store_backend/openapi"
+	"store_backend/repositories"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mountVersions registers the routes of every API version the way Initialize
+// does, with the default environment. The repositories are not used until a
+// request is served.
+func mountVersions(t *testing.T) (*echo.Echo, *openapi.Spec) {
+	t.Helper()
+
+	t.Setenv("ENV", "development")
+	t.Setenv("DATABASE_URI", "unused")
+	t.Setenv("JWT_SIGNING_KEY", "test")
+	env := environment.Initialize()
+
+	e := echo.New()
+	spec := openapi.New(openapi.Info{Title: "Store API", Version: "test"})
+	usage := newUsage()
+
+	for _, version := range handlers.Initialize(repositories.Repositories{}, env) {
+		if err := mountVersion(e, spec, version, usage); err != nil {
+			t.Fatalf("mounting %s: %v", version.Name, err)
+		}
+	}
+	if err := spec.RegisterRoutes(e); err != nil {
+		t.Fatalf("registering the spec routes: %v", err)
+	}
+
+	return e, spec
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	e, spec := mountVersions(t)
+
+	if err := spec.Build(e.Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndocumentedRouteFailsBuild(t *testing.T) {
+	e, spec := mountVersions(t)
+
+	e.GET("/v1/undocumented", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	err := spec.Build(e.Routes())
+	if err == nil {
+		t.Fatal("expected an error for the undocumented route")
+	}
+	if !strings.Contains(err.Error(), "GET /v1/undocumented") {
+		t.Errorf("error does not name the undocumented route: %v", err)
+	}
+}
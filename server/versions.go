@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"store_backend/handlers"
+	"store_backend/openapi"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mountVersion registers the routes of an API version under its prefix and
+// documents them. Operations of a deprecated version are deprecated unless
+// they were deprecated on their own.
+func mountVersion(e *echo.Echo, spec *openapi.Spec, version handlers.Version, usage *usage) error {
+	deprecated := map[string]openapi.Operation{}
+	g := e.Group("/"+version.Name, track(version.Name, deprecated, usage))
+
+	for _, handler := range version.Handlers {
+		if err := handler.RegisterRoutes(g); err != nil {
+			return err
+		}
+
+		for _, op := range handler.Operations() {
+			op.Version = version.Name
+			if op.Deprecated.IsZero() {
+				op.Deprecated, op.Sunset = version.Deprecated, version.Sunset
+			}
+			if !op.Deprecated.IsZero() {
+				deprecated[op.Method+" "+op.Route()] = op
+			}
+			spec.Add(op)
+		}
+	}
+
+	return nil
+}
+
+// track counts the requests to an API version and announces deprecated
+// routes with Deprecation (RFC 9745) and Sunset (RFC 8594) headers.
+func track(version string, deprecated map[string]openapi.Operation, usage *usage) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route := c.Request().Method + " " + c.Path()
+
+			op, isDeprecated := deprecated[route]
+			if isDeprecated {
+				header := c.Response().Header()
+				header.Set("Deprecation", fmt.Sprintf("@%d", op.Deprecated.Unix()))
+				if !op.Sunset.IsZero() {
+					header.Set("Sunset", op.Sunset.UTC().Format(http.TimeFormat))
+				}
+				header.Add("Link", `</docs>; rel="deprecation"; type="text/html"`)
+			}
+
+			usage.record(version, route, isDeprecated)
+
+			return next(c)
+		}
+	}
+}
+
+// usage counts the requests to each API version and route since the last
+// report.
+type usage struct {
+	mu       sync.Mutex
+	versions map[string]*versionUsage
+}
+
+type versionUsage struct {
+	requests   int
+	deprecated int
+	routes     map[string]int
+}
+
+func newUsage() *usage {
+	return &usage{versions: map[string]*versionUsage{}}
+}
+
+func (u *usage) record(version string, route string, deprecated bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	v, ok := u.versions[version]
+	if !ok {
+		v = &versionUsage{routes: map[string]int{}}
+		u.versions[version] = v
+	}
+
+	v.requests++
+	if deprecated {
+		v.deprecated++
+	}
+	v.routes[route]++
+}
+
+// report logs the requests to each version and its routes, then starts
+// counting anew.
+func (u *usage) report(logger *slog.Logger) {
+	u.mu.Lock()
+	versions := u.versions
+	u.versions = map[string]*versionUsage{}
+	u.mu.Unlock()
+
+	for _, name := range slices.Sorted(maps.Keys(versions)) {
+		v := versions[name]
+		logger.Info("api usage",
+			"version", name,
+			"requests", v.requests,
+			"deprecated", v.deprecated,
+			"routes", v.routes,
+		)
+	}
+}
+
+// start reports the usage every interval in the background. Usage is not
+// reported if the interval is not positive.
+func (u *usage) start(logger *slog.Logger, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for range ticker.C {
+			u.report(logger)
+		}
+	}()
+}